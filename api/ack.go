@@ -0,0 +1,90 @@
+package api
+
+import (
+	"chain-proxy/service"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// 确认接口的访问令牌请求头
+const ackTokenHeader = "X-Ack-Token"
+
+func AckGroup(g *gin.Engine) {
+	cg := g.Group("/chainProxy", ackAuth)
+	{
+		cg.POST("ack", AckEvents)
+		cg.POST("nack", NackEvents)
+	}
+}
+
+// ackAuth 校验确认接口的访问令牌，未开启确认或未配置令牌时拒绝所有请求
+func ackAuth(ctx *gin.Context) {
+	token := service.AckToken()
+	if token == "" || subtle.ConstantTimeCompare([]byte(ctx.GetHeader(ackTokenHeader)), []byte(token)) != 1 {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"code": -1,
+			"msg":  "unauthorized",
+		})
+		return
+	}
+
+	ctx.Next()
+}
+
+// AckEvents 接收方确认已收到同步记录
+func AckEvents(ctx *gin.Context) {
+	handleAck(ctx, service.AckEvents)
+}
+
+// NackEvents 接收方拒绝同步记录，记录退避后重新推送
+func NackEvents(ctx *gin.Context) {
+	handleAck(ctx, service.NackEvents)
+}
+
+func handleAck(ctx *gin.Context, fn func(req *service.AckRequest) (int64, error)) {
+	req, err := ctx.GetRawData()
+	if err != nil {
+		fmt.Println(err)
+		ctx.JSON(http.StatusOK, gin.H{
+			"code": -1,
+		})
+		return
+	}
+
+	ar := new(service.AckRequest)
+	err = json.Unmarshal(req, ar)
+	if err != nil {
+		ctx.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "invalid request",
+		})
+		return
+	}
+
+	n, err := fn(ar)
+	if err != nil {
+		ctx.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	if n == 0 {
+		ctx.JSON(http.StatusConflict, gin.H{
+			"code": -1,
+			"msg":  "no event awaiting acknowledgement",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"affected": n,
+		},
+	})
+}
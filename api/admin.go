@@ -0,0 +1,236 @@
+package api
+
+import (
+	"chain-proxy/config"
+	"chain-proxy/service"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// 管理接口的访问令牌请求头
+const adminTokenHeader = "X-Admin-Token"
+
+func AdminGroup(g *gin.Engine) {
+	ag := g.Group("/chainProxy/admin", adminAuth)
+	{
+		ag.POST("backfill", Backfill)
+		ag.POST("release", ReleaseUser)
+		ag.GET("reconciliation", ListReconciliationReports)
+		ag.GET("deadLetters", ListDeadLetters)
+		ag.POST("deadLetters/redrive", RedriveDeadLetter)
+		ag.GET("lanes", ListLaneDepths)
+		ag.GET("listeners", ListListeners)
+	}
+}
+
+// adminAuth 校验管理接口的访问令牌，未配置令牌时拒绝所有请求
+func adminAuth(ctx *gin.Context) {
+	var token string
+	if ac := config.GetConfigInstance().Admin; ac != nil {
+		token = ac.Token
+	}
+
+	if token == "" || subtle.ConstantTimeCompare([]byte(ctx.GetHeader(adminTokenHeader)), []byte(token)) != 1 {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"code": -1,
+			"msg":  "unauthorized",
+		})
+		return
+	}
+
+	ctx.Next()
+}
+
+// ListListeners 查询运行中的监听任务及其重新订阅次数
+func ListListeners(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"listeners": service.ListenerStatuses(),
+		},
+	})
+}
+
+// ListLaneDepths 查询推送分发各通道的排队数量
+func ListLaneDepths(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"lanes": service.LaneDepths(),
+		},
+	})
+}
+
+// ListDeadLetters 分页查询死信事件，status 为空时查询全部
+func ListDeadLetters(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.Query("page"))
+	size, _ := strconv.Atoi(ctx.Query("size"))
+	status, err := strconv.Atoi(ctx.DefaultQuery("status", "-1"))
+	if err != nil {
+		ctx.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "invalid status",
+		})
+		return
+	}
+
+	dls, total, err := service.ListDeadLetters(status, page, size)
+	if err != nil {
+		ctx.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"total":       total,
+			"deadLetters": dls,
+		},
+	})
+}
+
+type redriveRequest struct {
+	Id int `json:"id"`
+}
+
+// RedriveDeadLetter 重新投递死信事件
+func RedriveDeadLetter(ctx *gin.Context) {
+	req, err := ctx.GetRawData()
+	if err != nil {
+		fmt.Println(err)
+		ctx.JSON(http.StatusOK, gin.H{
+			"code": -1,
+		})
+		return
+	}
+
+	rr := new(redriveRequest)
+	err = json.Unmarshal(req, rr)
+	if err != nil || rr.Id == 0 {
+		ctx.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "id is required",
+		})
+		return
+	}
+
+	resp, err := service.RedriveDeadLetter(rr.Id)
+	if err != nil {
+		ctx.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": resp,
+	})
+}
+
+// ListReconciliationReports 分页查询对账报告
+func ListReconciliationReports(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.Query("page"))
+	size, _ := strconv.Atoi(ctx.Query("size"))
+
+	reports, total, err := service.ListReconciliationReports(ctx.Query("runId"), ctx.Query("userId"), page, size)
+	if err != nil {
+		ctx.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"total":   total,
+			"reports": reports,
+		},
+	})
+}
+
+type releaseRequest struct {
+	UserId string `json:"userid"`
+}
+
+// ReleaseUser 人工确认余额异常后，释放被暂停推送的用户
+func ReleaseUser(ctx *gin.Context) {
+	req, err := ctx.GetRawData()
+	if err != nil {
+		fmt.Println(err)
+		ctx.JSON(http.StatusOK, gin.H{
+			"code": -1,
+		})
+		return
+	}
+
+	rr := new(releaseRequest)
+	err = json.Unmarshal(req, rr)
+	if err != nil || rr.UserId == "" {
+		ctx.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "userid is required",
+		})
+		return
+	}
+
+	err = service.ReleaseUser(rr.UserId)
+	if err != nil {
+		ctx.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code": 0,
+	})
+}
+
+// Backfill 按区块区间回填历史事件
+func Backfill(ctx *gin.Context) {
+	req, err := ctx.GetRawData()
+	if err != nil {
+		fmt.Println(err)
+		ctx.JSON(http.StatusOK, gin.H{
+			"code": -1,
+		})
+		return
+	}
+
+	br := new(service.BackfillRequest)
+	err = json.Unmarshal(req, br)
+	if err != nil {
+		ctx.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	resp, err := service.Backfill(ctx.Request.Context(), br)
+	if err != nil {
+		ctx.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  err.Error(),
+			"data": resp,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": resp,
+	})
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"chain-proxy/service"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+func ConsumerGroup(g *gin.Engine) {
+	cg := g.Group("/chainProxy/consumer")
+	{
+		cg.GET("events", PullEvents)
+		cg.POST("commit", CommitCursor)
+	}
+}
+
+// PullEvents 按游标拉取同步记录，consumer 不为空且未携带 cursor 时从该消费方已提交的游标开始
+func PullEvents(ctx *gin.Context) {
+	limit, _ := strconv.Atoi(ctx.Query("limit"))
+	status, err := strconv.Atoi(ctx.DefaultQuery("status", "-1"))
+	if err != nil {
+		ctx.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "invalid status",
+		})
+		return
+	}
+
+	resp, err := service.PullEvents(&service.PullRequest{
+		Consumer: ctx.Query("consumer"),
+		Cursor:   ctx.Query("cursor"),
+		Topic:    ctx.Query("topic"),
+		UserId:   ctx.Query("userId"),
+		Status:   status,
+		Limit:    limit,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": resp,
+	})
+}
+
+type commitRequest struct {
+	Consumer string `json:"consumer"`
+	Cursor   string `json:"cursor"`
+}
+
+// CommitCursor 提交消费方已处理完的游标
+func CommitCursor(ctx *gin.Context) {
+	req, err := ctx.GetRawData()
+	if err != nil {
+		fmt.Println(err)
+		ctx.JSON(http.StatusOK, gin.H{
+			"code": -1,
+		})
+		return
+	}
+
+	cr := new(commitRequest)
+	err = json.Unmarshal(req, cr)
+	if err != nil {
+		ctx.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "invalid request",
+		})
+		return
+	}
+
+	err = service.CommitCursor(cr.Consumer, cr.Cursor)
+	if err != nil {
+		ctx.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code": 0,
+	})
+}
@@ -1,10 +1,12 @@
 package api
 
 import (
+	"chain-proxy/config"
 	"context"
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"net"
 	"net/http"
 )
 
@@ -22,7 +24,7 @@ func groupInit() *gin.Engine {
 	r := gin.Default()
 
 	// http router engine
-	register(AuthGroup)
+	register(AuthGroup, AdminGroup, AckGroup, ConsumerGroup, StreamGroup)
 
 	// 实例化http server
 	for _, opt := range options {
@@ -34,8 +36,12 @@ func groupInit() *gin.Engine {
 
 func Run(ctx context.Context) error {
 	server := &http.Server{
-		Addr:    fmt.Sprintf("%s:%d", "127.0.0.1", 10086),
+		Addr:    config.GetConfigInstance().ServerAddr(),
 		Handler: groupInit(),
+		// 请求的上下文随服务一起取消，使 sse 等长连接在退出时能够结束
+		BaseContext: func(net.Listener) context.Context {
+			return ctx
+		},
 	}
 
 	// 启动http server
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"chain-proxy/service"
+	"fmt"
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 没有新记录通知时的轮询间隔，覆盖其他实例或直接写库产生的记录，同时作为连接保活
+const streamPollInterval = 5 * time.Second
+
+func StreamGroup(g *gin.Engine) {
+	cg := g.Group("/chainProxy")
+	{
+		cg.GET("events/stream", StreamEvents)
+	}
+}
+
+// StreamEvents 以 SSE 推送新落库的同步记录，可按 userId、topic 过滤
+// 事件 id 为同步记录 id，断线重连时携带 Last-Event-ID 请求头（或 lastEventId 参数）从该 id 之后重放
+func StreamEvents(ctx *gin.Context) {
+	topic, userId := ctx.Query("topic"), ctx.Query("userId")
+
+	lastId := ctx.GetHeader("Last-Event-ID")
+	if lastId == "" {
+		lastId = ctx.Query("lastEventId")
+	}
+
+	var (
+		after int
+		err   error
+	)
+	if lastId != "" {
+		after, err = strconv.Atoi(lastId)
+	} else {
+		after, err = service.LatestEventId()
+	}
+	if err != nil {
+		ctx.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	// 先订阅通知再读取，避免读取与订阅之间落库的记录被遗漏
+	notify, cancel := service.SubscribeInserts()
+	defer cancel()
+
+	ticker := time.NewTicker(streamPollInterval)
+	defer ticker.Stop()
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Status(http.StatusOK)
+	ctx.Writer.Flush()
+
+	for {
+		srs, err := service.StreamEventsAfter(after, topic, userId)
+		if err != nil {
+			fmt.Printf("stream events after %d failed: %v\n", after, err)
+		}
+
+		for _, sr := range srs {
+			ctx.Render(-1, sse.Event{
+				Id:    strconv.Itoa(sr.ID),
+				Event: sr.EventType,
+				Data:  sr,
+			})
+			after = sr.ID
+		}
+		ctx.Writer.Flush()
+
+		// 一批未读完时继续读取
+		if err == nil && len(srs) != 0 {
+			continue
+		}
+
+		select {
+		case <-notify:
+			// 新记录需过了等待提交的时间窗口才会被读取
+			select {
+			case <-time.After(service.EventSettleDelay):
+			case <-ctx.Request.Context().Done():
+				return
+			}
+		case <-ticker.C:
+			// sse 注释行，用于保活
+			_, _ = ctx.Writer.WriteString(": ping\n\n")
+			ctx.Writer.Flush()
+		case <-ctx.Request.Context().Done():
+			return
+		}
+	}
+}
@@ -2,18 +2,35 @@ package chain
 
 import (
 	"chain-proxy/config"
+	"chainmaker.org/chainmaker/pb-go/v2/common"
 	cmsdk "chainmaker.org/chainmaker/sdk-go/v2"
 	"context"
 	"fmt"
+	"sync"
 )
 
+// EventSource 链上事件源，屏蔽真实链客户端与 mock 客户端的差异
+// chain.BCClient 与 mock.MockClient 均实现了该接口，由配置决定使用哪一个
+type EventSource interface {
+	// SubscribeContractEvent 订阅合约事件，end 为 -1 时持续监听
+	SubscribeContractEvent(ctx context.Context, start, end int64, contract, topic string) (<-chan interface{}, error)
+	// GetCurrentBlockHeight 查询当前区块高度
+	GetCurrentBlockHeight() (uint64, error)
+	// GetTxByTxId 根据交易 id 查询交易
+	GetTxByTxId(txId string) (*common.TransactionInfo, error)
+}
+
 type BCClient struct {
 	cmClient      *cmsdk.ChainClient
 	chainId       string
 	sdkConfigPath string
 }
 
-var Client = new(BCClient)
+var (
+	mu      sync.RWMutex
+	clients = make(map[string]*BCClient)   // 按 chain id 注册的链客户端
+	sources = make(map[string]EventSource) // 按 chain id 注册的事件源
+)
 
 func newBCClient(chainId string, sdkConfigPath string) (*BCClient, error) {
 	cli, err := cmsdk.NewChainClient(
@@ -30,33 +47,89 @@ func newBCClient(chainId string, sdkConfigPath string) (*BCClient, error) {
 	}, nil
 }
 
-func InitBCClient() error {
-	var (
-		err      error
-		chainId  = config.GetConfigInstance().ChainClient.ChainId
-		confPath = config.GetConfigInstance().ChainClient.SdkConfigPath
-	)
+// InitBCClients 根据应用链配置列表初始化链客户端并注册，mock 事件源的链会被跳过
+func InitBCClients() error {
+	for _, cc := range config.GetConfigInstance().ChainClients() {
+		if cc.EventSource != "" && cc.EventSource != config.EventSourceChain {
+			continue
+		}
+
+		err := InitBCClient(cc.ChainId, cc.SdkConfigPath)
+		if err != nil {
+			return fmt.Errorf("init bc client of chain %s failed: %w", cc.ChainId, err)
+		}
+	}
 
-	if Client.cmClient != nil {
+	return nil
+}
+
+// InitBCClient 初始化单条链的客户端并注册，已注册的 chain id 不会重复初始化
+func InitBCClient(chainId, confPath string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := clients[chainId]; ok {
 		return nil
 	}
 
-	Client, err = newBCClient(chainId, confPath)
+	cli, err := newBCClient(chainId, confPath)
 	if err != nil {
 		return err
 	}
 
-	_, err = Client.cmClient.GetPoolStatus()
+	_, err = cli.cmClient.GetPoolStatus()
 	if err != nil {
 		return err
 	}
 
-	fmt.Println("init bc client success")
+	clients[chainId] = cli
+	fmt.Printf("init bc client of chain %s success\n", chainId)
+
+	return nil
+}
+
+// GetClient 根据 chain id 获取已注册的链客户端
+func GetClient(chainId string) (*BCClient, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	cli, ok := clients[chainId]
+	return cli, ok
+}
+
+// RegisterEventSource 注册应用链使用的事件源
+func RegisterEventSource(chainId string, src EventSource) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	sources[chainId] = src
+}
+
+// GetEventSource 根据 chain id 获取已注册的事件源
+func GetEventSource(chainId string) (EventSource, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	src, ok := sources[chainId]
+	return src, ok
+}
+
+// ChainId 返回客户端所属的链 id
+func (c *BCClient) ChainId() string {
+	return c.chainId
+}
+
+// SubscribeContractEvent 订阅合约事件
+func (c *BCClient) SubscribeContractEvent(ctx context.Context, start, end int64, contract, topic string) (<-chan interface{}, error) {
+	return c.cmClient.SubscribeContractEvent(ctx, start, end, contract, topic)
+}
 
-	return err
+// GetCurrentBlockHeight 查询当前区块高度
+func (c *BCClient) GetCurrentBlockHeight() (uint64, error) {
+	return c.cmClient.GetCurrentBlockHeight()
 }
 
-// ListenContractEvents 监听合约信息
-func ListenContractEvents(ctx context.Context, start, end int64, contract, topic string) (<-chan interface{}, error) {
-	return Client.cmClient.SubscribeContractEvent(ctx, start, end, contract, topic)
+// GetTxByTxId 根据交易 id 查询交易
+func (c *BCClient) GetTxByTxId(txId string) (*common.TransactionInfo, error) {
+	return c.cmClient.GetTxByTxId(txId)
 }
@@ -0,0 +1,87 @@
+package chain
+
+import (
+	"chainmaker.org/chainmaker/pb-go/v2/common"
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+)
+
+var ErrNilEvent = errors.New("nil event received")
+
+// ContractEvent 代理服务内部使用的合约事件，由 *common.ContractEventInfo 直接转换而来
+type ContractEvent struct {
+	BlockHeight  int64    `json:"block_height"`
+	ChainId      string   `json:"chain_id"`
+	Topic        string   `json:"topic"`
+	TxId         string   `json:"tx_id"`
+	EventIndex   int      `json:"event_index"`
+	ContractName string   `json:"contract_name"`
+	EventData    []string `json:"event_data"`
+}
+
+// EventResult 订阅通道中的单条结果
+// 转换成功时 Event 非空；转换失败时 Err 非空，Raw 保留 sdk 推送的原始事件
+type EventResult struct {
+	Event *ContractEvent
+	Raw   interface{}
+	Err   error
+}
+
+// ConvertContractEvent 将 sdk 推送的事件转换为 ContractEvent
+func ConvertContractEvent(v interface{}) (*ContractEvent, error) {
+	switch ev := v.(type) {
+	case *common.ContractEventInfo:
+		if ev == nil {
+			return nil, ErrNilEvent
+		}
+
+		return &ContractEvent{
+			BlockHeight:  int64(ev.BlockHeight),
+			ChainId:      ev.ChainId,
+			Topic:        ev.Topic,
+			TxId:         ev.TxId,
+			EventIndex:   int(ev.EventIndex),
+			ContractName: ev.ContractName,
+			EventData:    ev.EventData,
+		}, nil
+	case nil:
+		return nil, ErrNilEvent
+	default:
+		return nil, fmt.Errorf("unexpected contract event type %T", v)
+	}
+}
+
+// ListenContractEvents 监听合约信息，并将 sdk 推送的事件转换为 ContractEvent
+func ListenContractEvents(ctx context.Context, src EventSource, start, end int64, contract, topic string) (<-chan *EventResult, error) {
+	rawCh, err := src.SubscribeContractEvent(ctx, start, end, contract, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	evCh := make(chan *EventResult)
+	go func() {
+		defer close(evCh)
+
+		for {
+			select {
+			case raw, ok := <-rawCh:
+				if !ok {
+					return
+				}
+
+				ev, err := ConvertContractEvent(raw)
+				select {
+				case evCh <- &EventResult{Event: ev, Raw: raw, Err: err}:
+				case <-ctx.Done():
+					return
+				}
+
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return evCh, nil
+}
@@ -0,0 +1,142 @@
+package chain
+
+import (
+	"chainmaker.org/chainmaker/pb-go/v2/common"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeSource 按顺序推送预置事件的事件源
+type fakeSource struct {
+	events []interface{}
+}
+
+func (s *fakeSource) SubscribeContractEvent(ctx context.Context, start, end int64, contract, topic string) (<-chan interface{}, error) {
+	ch := make(chan interface{}, len(s.events))
+	for _, ev := range s.events {
+		ch <- ev
+	}
+	close(ch)
+
+	return ch, nil
+}
+
+func (s *fakeSource) GetCurrentBlockHeight() (uint64, error) {
+	return 0, nil
+}
+
+func (s *fakeSource) GetTxByTxId(txId string) (*common.TransactionInfo, error) {
+	return nil, nil
+}
+
+func TestConvertContractEvent(t *testing.T) {
+	var typedNil *common.ContractEventInfo
+
+	tests := []struct {
+		name    string
+		in      interface{}
+		want    *ContractEvent
+		wantErr error
+		anyErr  bool
+	}{
+		{
+			name: "contract event info",
+			in: &common.ContractEventInfo{
+				BlockHeight:  128,
+				ChainId:      "chain1",
+				Topic:        "cic_topic",
+				TxId:         "tx1",
+				EventIndex:   2,
+				ContractName: "cc4",
+				EventData:    []string{`{"address":"a"}`},
+			},
+			want: &ContractEvent{
+				BlockHeight:  128,
+				ChainId:      "chain1",
+				Topic:        "cic_topic",
+				TxId:         "tx1",
+				EventIndex:   2,
+				ContractName: "cc4",
+				EventData:    []string{`{"address":"a"}`},
+			},
+		},
+		{
+			name:    "typed nil",
+			in:      typedNil,
+			wantErr: ErrNilEvent,
+		},
+		{
+			name:    "untyped nil",
+			in:      nil,
+			wantErr: ErrNilEvent,
+		},
+		{
+			name:   "unexpected type",
+			in:     "not an event",
+			anyErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConvertContractEvent(tt.in)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+			case tt.anyErr:
+				if err == nil {
+					t.Fatal("err = nil, want error")
+				}
+			default:
+				if err != nil {
+					t.Fatalf("unexpected err: %v", err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("got %+v, want %+v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestListenContractEvents(t *testing.T) {
+	good := &common.ContractEventInfo{BlockHeight: 10, TxId: "tx1"}
+	src := &fakeSource{events: []interface{}{good, 42}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	evCh, err := ListenContractEvents(ctx, src, 0, -1, "cc4", "cic_topic")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var results []*EventResult
+	for res := range evCh {
+		results = append(results, res)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+
+	if results[0].Err != nil || results[0].Event == nil || results[0].Event.BlockHeight != 10 {
+		t.Fatalf("unexpected first result %+v", results[0])
+	}
+
+	bad := results[1]
+	if bad.Err == nil {
+		t.Fatal("bad item should carry an error")
+	}
+	if bad.Event != nil {
+		t.Fatalf("bad item event = %+v, want nil", bad.Event)
+	}
+	if bad.Raw != 42 {
+		t.Fatalf("bad item raw = %v, want 42", bad.Raw)
+	}
+}
@@ -0,0 +1,101 @@
+package chain
+
+import (
+	"chain-proxy/config"
+	"chainmaker.org/chainmaker/pb-go/v2/common"
+	cmsdk "chainmaker.org/chainmaker/sdk-go/v2"
+	"fmt"
+	"github.com/pkg/errors"
+	"sync"
+)
+
+// 同步等待交易结果的默认超时，单位：秒
+const defaultInvokeTimeout = 10
+
+// TargetClient 数币链（同步目标链）客户端，使用独立的 sdk 配置
+type TargetClient struct {
+	cmClient *cmsdk.ChainClient
+	conf     *config.Target
+}
+
+var (
+	targetOnce sync.Once
+	target     *TargetClient
+	targetErr  error
+)
+
+// GetTargetClient 获取数币链客户端，首次调用时根据配置初始化，未配置时返回 nil
+func GetTargetClient() (*TargetClient, error) {
+	tc := config.GetConfigInstance().Target
+	if tc == nil || tc.SdkConfigPath == "" {
+		return nil, nil
+	}
+
+	targetOnce.Do(func() {
+		cli, err := cmsdk.NewChainClient(
+			cmsdk.WithConfPath(tc.SdkConfigPath),
+		)
+		if err != nil {
+			targetErr = err
+			return
+		}
+
+		target = &TargetClient{
+			cmClient: cli,
+			conf:     tc,
+		}
+		fmt.Printf("init target chain %s client success\n", tc.ChainId)
+	})
+
+	return target, targetErr
+}
+
+// Invoke 以同步等待结果的方式调用数币链上配置的合约方法，交易执行成功时返回交易 id
+// txId 由调用方按同步记录确定性地生成，同一记录重复调用时链上会拒绝重复的交易；
+// 调用失败（如等待结果超时后重试）时以链上已存在的同一交易的结果为准，合约不会被重复执行
+func (t *TargetClient) Invoke(txId string, kvs []*common.KeyValuePair) (string, error) {
+	timeout := t.conf.Timeout
+	if timeout <= 0 {
+		timeout = defaultInvokeTimeout
+	}
+
+	resp, err := t.cmClient.InvokeContract(t.conf.ContractName, t.conf.Method, txId, kvs, timeout, true)
+	if err == nil && resp != nil && resp.Code == common.TxStatusCode_SUCCESS {
+		return txId, t.checkResult(txId, resp.Code, resp.ContractResult, resp.Message)
+	}
+
+	// 交易可能已在之前的调用中上链
+	tx, qerr := t.cmClient.GetTxByTxId(txId)
+	if qerr == nil && tx != nil && tx.Transaction != nil && tx.Transaction.Result != nil {
+		res := tx.Transaction.Result
+		return txId, t.checkResult(txId, res.Code, res.ContractResult, res.Message)
+	}
+
+	if err != nil {
+		return "", err
+	}
+	if resp == nil {
+		return "", errors.Errorf("invoke contract %s method %s got empty response, tx %s", t.conf.ContractName, t.conf.Method, txId)
+	}
+
+	return txId, t.checkResult(txId, resp.Code, resp.ContractResult, resp.Message)
+}
+
+// checkResult 检查交易与合约的执行结果
+func (t *TargetClient) checkResult(txId string, code common.TxStatusCode, cr *common.ContractResult, message string) error {
+	if code != common.TxStatusCode_SUCCESS {
+		return errors.Errorf("invoke contract %s method %s failed, tx %s code %s, msg %s",
+			t.conf.ContractName, t.conf.Method, txId, code.String(), message)
+	}
+
+	if cr == nil || cr.Code != 0 {
+		msg := "empty contract result"
+		if cr != nil {
+			msg = cr.Message
+		}
+		return errors.Errorf("invoke contract %s method %s failed, tx %s contract result: %s",
+			t.conf.ContractName, t.conf.Method, txId, msg)
+	}
+
+	return nil
+}
@@ -12,23 +12,200 @@ import (
 
 type Config struct {
 	path        string
-	ChainClient *ChainClient `yaml:"chainClient"`
-	Gateway     *Gateway     `yaml:"gateway"`
-	MySQL       Mysql        `yaml:"mysql"` // 数据库
-	Gorm        Gorm         `yaml:"gorm"`  // gorm
+	ChainClient *ChainClient   `yaml:"chainClient"`
+	Chains      []*ChainClient `yaml:"chains"` // 多条应用链，为空时使用 ChainClient
+	Gateway     *Gateway       `yaml:"gateway"`
+	Target      *Target        `yaml:"target"` // 数币链
+	Sink        *Sink          `yaml:"sink"`   // 同步记录送达方式
+	Mock        *Mock          `yaml:"mock"`
+	Alert       *Alert         `yaml:"alert"`
+	Reconcile   *Reconcile     `yaml:"reconcile"`
+	Retry       *Retry         `yaml:"retry"` // 推送失败的战略重试
+	Lease       *Lease         `yaml:"lease"` // 推送认领的租约
+	Ack         *Ack           `yaml:"ack"`   // 接收方确认
+	Dispatch    *Dispatch      `yaml:"dispatch"`
+	Admin       *Admin         `yaml:"admin"`  // 管理接口
+	Server      *Server        `yaml:"server"` // http 服务
+	MySQL       Mysql          `yaml:"mysql"`  // 数据库
+	Gorm        Gorm           `yaml:"gorm"`   // gorm
 }
 
+const (
+	EventSourceChain = "chain" // 真实链客户端
+	EventSourceMock  = "mock"  // mock 客户端
+)
+
+// DefaultServerAddr 未配置 Server 时 http 服务的监听地址
+const DefaultServerAddr = "127.0.0.1:10086"
+
 type ChainClient struct {
 	ChainId       string `json:"chainId"`
 	SdkConfigPath string `json:"sdkConfigPath"`
 	DefaultHeight int64  `json:"defaultHeight"`
 	ContractName  string `json:"contractName"`
+	// 事件源：chain | mock，为空时默认为 chain
+	EventSource string `json:"eventSource"`
+	// 确认深度：链头高度超过事件所在高度该数量的区块后，事件才会被同步，为 0 时不等待
+	ConfirmDepth int64 `json:"confirmDepth"`
+	// 合约事件订阅列表，为空时使用 ContractName 监听碳积分变动 topic
+	Subscriptions []*Subscription `json:"subscriptions"`
+}
+
+// Subscription 单个合约事件订阅，每个订阅独立运行监听任务并记录各自的监听进度
+type Subscription struct {
+	ContractName string `json:"contractName"`
+	Topic        string `json:"topic"`
+	// 无监听进度时的起始高度，<= 0 时使用 DefaultHeight
+	StartHeight int64 `json:"startHeight"`
+	// 事件处理器名称，如 collect
+	Handler string `json:"handler"`
+}
+
+// ServerAddr 返回 http 服务的监听地址，未配置时只监听本机
+func (confIns *Config) ServerAddr() string {
+	if confIns.Server == nil || confIns.Server.Addr == "" {
+		return DefaultServerAddr
+	}
+
+	return confIns.Server.Addr
+}
+
+// ChainClients 返回需要监听的应用链配置列表，未配置 Chains 时兼容旧的单链配置
+func (confIns *Config) ChainClients() []*ChainClient {
+	if len(confIns.Chains) != 0 {
+		return confIns.Chains
+	}
+
+	if confIns.ChainClient == nil {
+		return nil
+	}
+
+	return []*ChainClient{confIns.ChainClient}
+}
+
+// Mock 事件源配置，仅在 EventSource 为 mock 时生效
+type Mock struct {
+	// 模拟产生余额变动的钱包地址
+	Addrs []string `json:"addrs"`
+	// 模拟出块间隔，单位：秒
+	Interval int `json:"interval"`
 }
 
 type Gateway struct {
 	Addr string `json:"addr"`
 }
 
+// Target 数币链（同步目标链）配置，同步记录通过调用该链上的合约方法送达
+type Target struct {
+	ChainId       string `json:"chainId"`
+	SdkConfigPath string `json:"sdkConfigPath"`
+	ContractName  string `json:"contractName"`
+	Method        string `json:"method"`
+	// 同步等待交易结果的超时，单位：秒
+	Timeout int64 `json:"timeout"`
+}
+
+// Sink 同步记录送达方式配置
+type Sink struct {
+	// 送达方式：chain | webhook | redis | log（仅打印，记录会被标记为成功，只用于开发环境），必须配置
+	Type    string   `json:"type"`
+	Webhook *Webhook `json:"webhook"`
+	Redis   *Redis   `json:"redis"`
+	// 批量送达，仅 webhook、redis 支持，未配置时逐条送达
+	Batch *Batch `json:"batch"`
+}
+
+// Batch 批量送达配置，达到任一上限即送达一批
+type Batch struct {
+	// 每批最多的事件数，<= 1 时不开启批量
+	MaxCount int `json:"maxCount"`
+	// 每批事件序列化后的最大字节数，<= 0 时不限制
+	MaxBytes int `json:"maxBytes"`
+	// 凑批的最长等待时间，单位：毫秒
+	Linger int `json:"linger"`
+}
+
+// Webhook http 推送配置
+type Webhook struct {
+	Url string `json:"url"`
+	// 请求超时，单位：秒
+	Timeout int `json:"timeout"`
+	// 附加的请求头
+	Headers map[string]string `json:"headers"`
+}
+
+type Redis struct {
+	Addr     string `json:"addr"`
+	Password string `json:"password"`
+	DB       int    `json:"db"`
+	// 写入的 stream 名称
+	Stream string `json:"stream"`
+	// stream 的近似最大长度，<= 0 时不裁剪
+	MaxLen int64 `json:"maxLen"`
+}
+
+// Reconcile 定时对账配置
+type Reconcile struct {
+	// 对账间隔，单位：秒，<= 0 时不开启对账
+	Interval int `json:"interval"`
+}
+
+// Retry 推送失败的战略重试配置，未配置时使用默认值
+type Retry struct {
+	// 扫描到期记录的间隔，单位：秒，<= 0 时使用默认值，重试调度始终运行
+	Interval int `json:"interval"`
+	// 每次扫描最多重新推送的记录数
+	BatchSize int `json:"batchSize"`
+	// 指数退避的初始与最大等待时间，单位：秒
+	BaseBackoff int `json:"baseBackoff"`
+	MaxBackoff  int `json:"maxBackoff"`
+	// 最大战略重试次数，<= 0 时使用默认值，处理器推送策略中的配置优先
+	MaxAttempts int `json:"maxAttempts"`
+}
+
+// Lease 推送认领的租约配置，未配置时使用默认值
+type Lease struct {
+	// 租约时长，单位：秒，需大于一次推送（含战术重试）的最长耗时
+	Duration int `json:"duration"`
+	// 回收过期租约的间隔，单位：秒
+	SweepInterval int `json:"sweepInterval"`
+}
+
+// Ack 接收方确认配置，开启后 sink 送达的记录保持已发送，接收方调用确认接口后才标记为成功
+type Ack struct {
+	Enabled bool `json:"enabled"`
+	// 确认接口的访问令牌，请求头 X-Ack-Token 需与之一致，为空时确认接口不可用
+	Token string `json:"token"`
+	// 确认超时，单位：秒，超时未确认的记录计入一次战略失败并重新推送
+	Timeout int `json:"timeout"`
+}
+
+// Dispatch 推送分发配置，按用户哈希到多个通道，同一用户有序推送，不同用户并发推送
+type Dispatch struct {
+	// 通道数量，<= 0 时使用默认值
+	Lanes int `json:"lanes"`
+	// 每个通道的队列长度，队列满时监听任务等待
+	QueueSize int `json:"queueSize"`
+}
+
+// Admin 管理接口配置
+type Admin struct {
+	// 管理接口的访问令牌，请求头 X-Admin-Token 需与之一致，为空时管理接口不可用
+	Token string `json:"token"`
+}
+
+// Server http 服务配置
+type Server struct {
+	// 监听地址，格式为 host:port，对外提供拉取消费与 sse 推送时需监听外部可访问的地址
+	Addr string `json:"addr"`
+}
+
+// Alert 告警配置
+type Alert struct {
+	// 告警通知地址，为空时仅打印日志
+	WebhookUrl string `json:"webhookUrl"`
+}
+
 var (
 	once           sync.Once
 	conf           *Config
@@ -39,11 +216,6 @@ func init() {
 	once.Do(func() {
 		conf = new(Config)
 	})
-
-	err := checkConfigEnv()
-	if err != nil {
-		panic(err)
-	}
 }
 
 // checkConfigEnv 检擦配置环境变量是否设置
@@ -57,11 +229,17 @@ func checkConfigEnv() error {
 }
 
 // LoadConfig 加载配置文件
+// 配置目录在加载时才检查，单元测试等不加载配置的场景可以正常引用本包
 func LoadConfig() error {
+	err := checkConfigEnv()
+	if err != nil {
+		return err
+	}
+
 	viper.AddConfigPath(conf.path)
 	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
-	err := viper.ReadInConfig()
+	err = viper.ReadInConfig()
 	if err != nil {
 		return fmt.Errorf("Fatal error config file: %w \n", err)
 	}
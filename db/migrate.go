@@ -0,0 +1,117 @@
+package db
+
+import (
+	"chain-proxy/config"
+	"chain-proxy/db/model"
+)
+
+// AutoMigrate 根据模型自动创建或补全数据表
+func AutoMigrate() error {
+	err := prepareSyncEventKey()
+	if err != nil {
+		return err
+	}
+
+	err = prepareChecked()
+	if err != nil {
+		return err
+	}
+
+	tables := []struct {
+		name  string
+		model interface{}
+	}{
+		{model.TableUserAuth, &model.UserAuth{}},
+		{model.TableSyncEventLog, &model.SyncEventLog{}},
+		{model.TableListenerCheckpoint, &model.ListenerCheckpoint{}},
+		{model.TableIntegralSplitLog, &model.IntegralSplitLog{}},
+		{model.TableReconciliationReport, &model.ReconciliationReport{}},
+		{model.TableDeadLetterEvent, &model.DeadLetterEvent{}},
+		{model.TableConsumerCursor, &model.ConsumerCursor{}},
+	}
+
+	for _, t := range tables {
+		err := GetGormDb().Table(t.name).AutoMigrate(t.model)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// prepareSyncEventKey 创建同步记录唯一键 uk_sync_event 之前整理已有数据，唯一键已存在时不做处理
+// 旧版本的记录没有 chain_id、event_index、sub_index，且重新扫描起始高度时会重复写入同一交易的记录：
+// 1. chain_id 为空的记录补为配置中的第一条应用链；
+// 2. 同一交易下同一用户的重复记录只保留一条，优先保留已成功的记录，被删除记录的拆分明细一并删除；
+// 3. 同一交易下的其余记录（批量事件中的不同用户）按 id 顺序补齐 sub_index
+func prepareSyncEventKey() error {
+	sr := &model.SyncEventLog{}
+	m := GetGormDb().Table(model.TableSyncEventLog).Migrator()
+	if !m.HasTable(model.TableSyncEventLog) || m.HasIndex(sr, "uk_sync_event") {
+		return nil
+	}
+
+	for _, field := range []string{"ChainId", "EventIndex", "SubIndex"} {
+		if m.HasColumn(sr, field) {
+			continue
+		}
+		err := GetGormDb().Table(model.TableSyncEventLog).Migrator().AddColumn(sr, field)
+		if err != nil {
+			return err
+		}
+	}
+
+	if ccs := config.GetConfigInstance().ChainClients(); len(ccs) != 0 {
+		err := GetGormDb().
+			Table(model.TableSyncEventLog).
+			Where("chain_id = ''").
+			Update("chain_id", ccs[0].ChainId).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	// sync_status 2 为已成功
+	err := GetGormDb().Exec(`DELETE d FROM ` + model.TableSyncEventLog + ` d
+		JOIN ` + model.TableSyncEventLog + ` k ON k.chain_id = d.chain_id AND k.tx_id = d.tx_id
+			AND k.event_index = d.event_index AND k.user_id = d.user_id
+			AND ((k.sync_status = 2 AND d.sync_status <> 2)
+				OR ((k.sync_status = 2) = (d.sync_status = 2) AND k.id < d.id))`).Error
+	if err != nil {
+		return err
+	}
+
+	if GetGormDb().Migrator().HasTable(model.TableIntegralSplitLog) {
+		err = GetGormDb().Exec(`DELETE l FROM ` + model.TableIntegralSplitLog + ` l
+			LEFT JOIN ` + model.TableSyncEventLog + ` s ON s.id = l.sync_event_id
+			WHERE s.id IS NULL`).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return GetGormDb().Exec(`UPDATE ` + model.TableSyncEventLog + ` s
+		JOIN (SELECT id, ROW_NUMBER() OVER (PARTITION BY chain_id, tx_id, event_index ORDER BY id) - 1 AS rn
+			FROM ` + model.TableSyncEventLog + `) r ON r.id = s.id
+		SET s.sub_index = r.rn`).Error
+}
+
+// prepareChecked 新增 checked 列时，已有记录视为已校验，避免升级后重新校验全部历史记录
+func prepareChecked() error {
+	sr := &model.SyncEventLog{}
+	m := GetGormDb().Table(model.TableSyncEventLog).Migrator()
+	if !m.HasTable(model.TableSyncEventLog) || m.HasColumn(sr, "Checked") {
+		return nil
+	}
+
+	err := m.AddColumn(sr, "Checked")
+	if err != nil {
+		return err
+	}
+
+	return GetGormDb().
+		Table(model.TableSyncEventLog).
+		Where("1 = 1").
+		Update(model.CheckedCol, true).Error
+}
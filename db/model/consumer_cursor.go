@@ -0,0 +1,14 @@
+package model
+
+// 拉取消费方的游标表
+// 1. 无法接收推送的消费方通过拉取接口按 sync_event_log 的 id 顺序读取同步记录；
+// 2. 每个消费方确认处理完一批记录后提交游标，这里记录其已提交的最大 id，只增不减；
+// 3. 拉取时未携带游标的消费方从已提交的位置继续读取。
+
+const TableConsumerCursor = "consumer_cursor"
+
+type ConsumerCursor struct {
+	CommonField
+	ConsumerId string `gorm:"size:64;uniqueIndex"`
+	LastId     int    // 已提交的 sync_event_log id
+}
@@ -0,0 +1,21 @@
+package model
+
+// 死信事件表
+// 1. 解析或校验失败的合约事件不再丢弃，原始事件以 json 形式保存在该表中；
+// 2. 解码器修复后，可通过管理接口重新投递，重新投递成功的事件标记为已重投。
+
+const TableDeadLetterEvent = "dead_letter_event"
+
+type DeadLetterEvent struct {
+	CommonField
+	ChainId      string `gorm:"size:64;index:idx_dead_letter_event"`
+	ContractName string `gorm:"size:128"`
+	Topic        string `gorm:"size:128"`
+	BlockHeight  int64
+	TxId         string `gorm:"size:128;index:idx_dead_letter_event"`
+	EventIndex   int
+	RawEvent     string `gorm:"type:text"` // 原始事件 json
+	ErrorReason  string `gorm:"type:text"` // 最近一次失败原因
+	Status       int    `gorm:"index"`     // 0：待处理；1：已重投
+	RedriveCount int    // 重新投递的次数
+}
@@ -0,0 +1,20 @@
+package model
+
+// 积分拆分明细表
+// 1. 记录每次拆分事件中，用户积分在未拆分（IntegralMap）与已拆分（SplitIntegralMap）之间的移动；
+// 2. 每个积分 hash 一条记录，与对应的 sync_event_log 同一事务写入。
+
+const TableIntegralSplitLog = "integral_split_log"
+
+type IntegralSplitLog struct {
+	CommonField
+	SyncEventId  int    `gorm:"uniqueIndex:uk_integral_split"` // 对应 sync_event_log 的 id
+	IntegralHash string `gorm:"size:128;uniqueIndex:uk_integral_split"`
+	ChainId      string `gorm:"size:64"`
+	UserId       string `gorm:"size:64;index"`
+	TxId         string `gorm:"size:128"`
+	BlockHeight  int64
+	Amount       int64 // 从未拆分移动到已拆分的数量
+	UnsplitAfter int64 // 移动后该 hash 的未拆分积分
+	SplitAfter   int64 // 移动后该 hash 的已拆分积分
+}
@@ -0,0 +1,16 @@
+package model
+
+// 监听进度表
+// 1. 按 chain id + 合约 + topic 记录监听任务已处理到的区块高度；
+// 2. 每处理完一个区块的事件后，在同一事务中推进该高度（未授权用户的事件同样会推进）；
+// 3. 服务重启或重新订阅时以该高度作为起始高度，不存在时使用配置的 DefaultHeight。
+
+const TableListenerCheckpoint = "listener_checkpoint"
+
+type ListenerCheckpoint struct {
+	CommonField
+	ChainId      string `gorm:"size:64;uniqueIndex:uk_listener_checkpoint"`
+	ContractName string `gorm:"size:128;uniqueIndex:uk_listener_checkpoint"`
+	Topic        string `gorm:"size:128;uniqueIndex:uk_listener_checkpoint"`
+	BlockHeight  int64
+}
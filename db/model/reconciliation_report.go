@@ -0,0 +1,20 @@
+package model
+
+// 对账报告表
+// 定时对账任务从 gateway 获取每个已授权用户的最新钱包状态，与最近一次同步的余额比较，
+// 不一致时写入该表，同一次对账的记录使用相同的 run_id。
+
+const TableReconciliationReport = "reconciliation_report"
+
+type ReconciliationReport struct {
+	CommonField
+	RunId         string `gorm:"size:64;index"` // 对账批次
+	UserId        string `gorm:"size:64;index"`
+	Addr          string `gorm:"size:128"`
+	ChainBalance  int64  // gateway 返回的链上最新余额
+	ChainHeight   int64  // gateway 返回的链上最新余额所在高度
+	SyncedBalance int64  // 最近一次同步的余额
+	SyncedHeight  int64  // 最近一次同步的余额所在高度
+	Diff          int64  // ChainBalance - SyncedBalance
+	Reason        string `gorm:"size:32"` // mismatch：同一高度下余额不一致；lagging：链上状态晚于已同步高度
+}
@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 // 同步事件信息表
 // 1. 同步每次从区块链捕获的用户事件信息
 // 2. 该表记录了同步信息的结构、同步结果（状态）、重试次数、错误信息、 block height
@@ -8,6 +10,17 @@ package model
 // 2. 当监听到已授权用户的变化动态时，解析区块：
 //   2.1 若change height >= 用户 init 的 height，说明当前变化是发生在 init 之后，则存储；
 //   2.2 若change height < 用户 init 的 height，说明当前变化发生在 init 之前，不存储；
+// 3. 批量方法的事件中 EventData 包含多项时，每一项单独存储一条记录，以 sub_index 区分，
+//   唯一键为 (chain_id, tx_id, event_index, sub_index)；
+// 4. 推送失败未达最大重试次数时恢复为待发送，next_retry_at 记录按指数退避计算的下次重试时间，
+//   由重试调度任务在到期后重新推送；
+// 5. 推送前以条件更新认领记录并置为已发送，locked_by/lease_until 记录认领的实例与租约到期时间，
+//   进程在推送中途退出时，租约到期后由回收任务恢复为待发送，保证每条记录至少推送一次；
+// 6. 同一用户在同一条链上的记录按 (block_height, event_index, sub_index, id) 顺序推送，
+//   更早的记录未成功、失败或忽略之前，后续记录保持待发送；
+// 7. 开启接收方确认时，送达后记录 delivered_at 并保持已发送，认领后即可被确认，超时未确认计入一次战略失败；
+// 8. 各订阅独立监听，同一用户不同 topic 的记录到达顺序与区块顺序不一致，所有订阅的监听进度都越过记录所在高度后，
+//   才按区块顺序校验余额连续性并置 checked，未校验的记录不会推送；
 // 用户余额同步后还存在的问题【极低概率】
 // 用户 balance 在保存到该表之前，发生了变动（除非该用户在做该操作时，同步是进行收集或兑换操作）
 // 如果要防止该情况的出现，可以加一步同步完后的校验接口（获取 gateway 余额？）
@@ -16,18 +29,36 @@ const (
 	TableSyncEventLog = "sync_event_log"
 	SyncStatusCol     = "sync_status"
 	RetryCountCol     = "retry_count"
+	ErrorMessageCol   = "error_message"
+	TargetTxIdCol     = "target_tx_id"
+	NextRetryAtCol    = "next_retry_at"
+	LockedByCol       = "locked_by"
+	LeaseUntilCol     = "lease_until"
+	DeliveredAtCol    = "delivered_at"
+	CheckedCol        = "checked"
 )
 
 type SyncEventLog struct {
 	CommonField
-	UserId       string
-	BlockHeight  int64
+	ChainId      string `gorm:"size:64;uniqueIndex:uk_sync_event;index:idx_sync_user_order,priority:2;index:idx_sync_unchecked,priority:2"` // 事件来源的应用链
+	UserId       string `gorm:"size:128;index:idx_sync_user_order,priority:1"`
+	BlockHeight  int64  `gorm:"index:idx_sync_user_order,priority:3;index:idx_sync_unchecked,priority:3"`
 	BalanceAfter int64
 	ChangeValue  int64
 	Topic        string
-	TxId         string
+	EventType    string `gorm:"size:32"` // 事件处理器名称，如 collect
+	TxId         string `gorm:"size:128;uniqueIndex:uk_sync_event"`
+	EventIndex   int    `gorm:"uniqueIndex:uk_sync_event;index:idx_sync_user_order,priority:4"` // 事件在交易中的序号
+	SubIndex     int    `gorm:"uniqueIndex:uk_sync_event;index:idx_sync_user_order,priority:5"` // 数据项在 EventData 中的序号
 	ContractName string
-	SyncStatus   int
+	SyncStatus   int `gorm:"index:idx_sync_retry,priority:1"`
 	RetryCount   int
 	ErrorMessage string
+	EventPayload string     `gorm:"type:text"`                       // 推送给接收方的附加数据
+	TargetTxId   string     `gorm:"size:128"`                        // 数币链上的同步交易 id
+	NextRetryAt  *time.Time `gorm:"index:idx_sync_retry,priority:2"` // 下次重试时间，为空表示尚未失败过
+	LockedBy     string     `gorm:"size:128"`                        // 认领推送的实例
+	LeaseUntil   *time.Time `gorm:"index"`                           // 认领的租约到期时间
+	DeliveredAt  *time.Time // sink 送达时间，认领时清空，开启确认时只有已送达的记录可被确认
+	Checked      bool       `gorm:"index:idx_sync_unchecked,priority:1"` // 已按区块顺序校验余额连续性
 }
@@ -6,6 +6,8 @@ import "time"
 // 1. 用户 id 完成主要逻辑；
 // 2. addr 完成用户的链上信息查询；
 // 3. 数币 dcid 标识；
+// 4. synced_balance/synced_height 记录最近一次同步的余额，用于校验事件余额的连续性，
+//   授权时以钱包历史状态为起点；held 表示余额不连续，暂停向接收方推送该用户的事件；
 
 const TableUserAuth = "user_auth"
 
@@ -16,6 +18,11 @@ type UserAuth struct {
 	Dcid        string `gorm:"unique"` // 数币唯一标识
 	BlockHeight int64
 	Balance     int64
+	// 最近一次同步的余额及高度，synced_height 为 0 时以 Balance 为准
+	SyncedBalance int64
+	SyncedHeight  int64
+	// 余额不连续时暂停推送，人工确认后释放
+	Held bool
 }
 
 type CommonField struct {
@@ -4,12 +4,16 @@ import (
 	"chain-proxy/api"
 	"chain-proxy/chain"
 	"chain-proxy/config"
+	"chain-proxy/db"
+	"chain-proxy/mock"
 	"chain-proxy/service"
 	"context"
+	"flag"
 	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
 func main() {
@@ -18,13 +22,62 @@ func main() {
 		panic(err)
 	}
 
-	err = chain.InitBCClient()
+	if config.GetConfigInstance().Gorm.EnableAutoMigrate {
+		err = db.AutoMigrate()
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	err = chain.InitBCClients()
+	if err != nil {
+		panic(err)
+	}
+
+	err = service.InitSink()
 	if err != nil {
 		panic(err)
 	}
 
+	for _, cc := range config.GetConfigInstance().ChainClients() {
+		source, err := newEventSource(cc)
+		if err != nil {
+			panic(err)
+		}
+		chain.RegisterEventSource(cc.ChainId, source)
+	}
+
+	// 历史区间回填子命令
+	if len(os.Args) > 1 && os.Args[1] == "backfill" {
+		err = runBackfill(os.Args[2:])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// 每条应用链的每个订阅各自运行一个监听任务
+	var listeners []*service.EventListener
+	for _, cc := range config.GetConfigInstance().ChainClients() {
+		source, _ := chain.GetEventSource(cc.ChainId)
+		for _, sub := range service.Subscriptions(cc) {
+			l, err := service.NewEventListener(cc, source, sub)
+			if err != nil {
+				panic(err)
+			}
+			listeners = append(listeners, l)
+		}
+	}
+
+	// api 服务、推送分发、对账任务、重试任务、租约回收任务与每个订阅各占用一个 worker
+	poolSize := 10
+	if len(listeners)+5 > poolSize {
+		poolSize = len(listeners) + 5
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
-	wp := service.NewWorkerPool(10, ctx, cancel)
+	wp := service.NewWorkerPool(poolSize, ctx, cancel)
 
 	// api 服务
 	err = wp.Submit(api.Run)
@@ -33,7 +86,37 @@ func main() {
 		return
 	}
 
-	err = wp.Submit(service.HandleCollectEvent)
+	// 推送分发，启动前监听任务写入的记录直接推送
+	err = wp.Submit(service.RunDispatcher)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	for _, l := range listeners {
+		err = wp.Submit(l.Run)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	// 定时对账
+	err = wp.Submit(service.Reconcile)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// 推送失败的战略重试
+	err = wp.Submit(service.RetryPending)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// 回收推送中途退出遗留的已发送记录
+	err = wp.Submit(service.SweepLeases)
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -48,3 +131,56 @@ func main() {
 
 	wp.Stop()
 }
+
+// newEventSource 根据应用链配置选择事件源
+func newEventSource(cc *config.ChainClient) (chain.EventSource, error) {
+	switch cc.EventSource {
+	case config.EventSourceMock:
+		var (
+			addrs    []string
+			interval time.Duration
+		)
+		if mc := config.GetConfigInstance().Mock; mc != nil {
+			addrs = mc.Addrs
+			interval = time.Duration(mc.Interval) * time.Second
+		}
+		fmt.Printf("chain %s use mock event source\n", cc.ChainId)
+		return mock.NewMockClient(cc.ChainId, addrs, interval), nil
+	case "", config.EventSourceChain:
+		cli, ok := chain.GetClient(cc.ChainId)
+		if !ok {
+			return nil, fmt.Errorf("bc client of chain %s not initialized", cc.ChainId)
+		}
+		return cli, nil
+	default:
+		return nil, fmt.Errorf("unknown event source %s of chain %s", cc.EventSource, cc.ChainId)
+	}
+}
+
+// runBackfill 执行回填子命令，如：
+// chain-proxy backfill -chain lcago -contract cc4 -topic cic_topic -start 100 -end 200
+func runBackfill(args []string) error {
+	req := new(service.BackfillRequest)
+	fs := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	fs.StringVar(&req.ChainId, "chain", "", "chain id, default the first configured chain")
+	fs.StringVar(&req.ContractName, "contract", "", "contract name")
+	fs.StringVar(&req.Topic, "topic", "", "event topic")
+	fs.StringVar(&req.Handler, "handler", "", "event handler, default the handler of the matched subscription")
+	fs.Int64Var(&req.Start, "start", 0, "start block height")
+	fs.Int64Var(&req.End, "end", 0, "end block height")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer cancel()
+
+	res, err := service.Backfill(ctx, req)
+	if res != nil {
+		fmt.Printf("backfill inserted: %d, skipped: %d, duplicated: %d, failed: %d\n",
+			res.Inserted, res.Skipped, res.Duplicated, res.Failed)
+	}
+
+	return err
+}
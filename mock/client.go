@@ -1,18 +1,110 @@
 package mock
 
 import (
+	"chainmaker.org/chainmaker/pb-go/v2/common"
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/pkg/errors"
+	"sync"
 	"time"
 )
 
-// MockClient 模拟你的 Client.cmClient
-type MockClient struct{}
+// 与 service 中的 topic 保持一致，按 topic 生成对应处理器能够解析的事件数据
+// 其余 topic（含收集事件 cic_topic）均产生收集事件
+const (
+	splitTopic    = "cis_topic"
+	exchangeTopic = "cie_topic"
+)
+
+const (
+	mockCollectValue  = 10 // 每次收集的积分
+	mockExchangeValue = 5  // 每次兑换消耗的积分
+	mockIntegralHash  = "mock_integral"
+)
+
+// MockClient 模拟链客户端，实现 chain.EventSource，用于开发及 CI 环境跑通同步流程
+// 模拟一条应用链：按间隔出块，每个块为已订阅的 topic 轮流产生一个事件，钱包余额是链上状态，所有 topic 共用；
+// 每个订阅从各自的起始高度回放已产生的事件，只接收所订阅 (合约, topic) 的事件
+type MockClient struct {
+	chainId  string
+	addrs    []string      // 模拟产生余额变动的钱包地址
+	interval time.Duration // 模拟出块间隔
+
+	mu      sync.Mutex
+	started bool
+	height  uint64
+	seq     int                         // 已产生的事件数，轮流选择 topic 与地址
+	wallets map[string]*mockWallet      // 按地址记录的钱包状态
+	topics  []*mockTopic                // 已订阅的 (合约, topic)
+	events  []*common.ContractEventInfo // 已产生的事件，按高度递增
+	txs     map[string]*common.TransactionInfo
+	block   chan struct{} // 出块时关闭并替换，通知订阅者
+}
+
+type mockTopic struct {
+	contract string
+	topic    string
+}
+
+// mockWallet 模拟的钱包，未拆分与已拆分积分都只有一个积分 hash
+type mockWallet struct {
+	unsplit int64
+	split   int64
+}
 
-type Event struct {
-	Topic string
-	Data  []byte
+func (w *mockWallet) balance() int64 {
+	return w.unsplit + w.split
+}
+
+// collectEventData 与 service.CollectEventInfo 的 json 结构保持一致
+type collectEventData struct {
+	Address     string `json:"address"`
+	Height      int64  `json:"height"`
+	Balance     int64  `json:"balance"`
+	ChangeValue int64  `json:"changeValue"`
+	TxId        string `json:"txId"`
+}
+
+// splitEventData 与 service.SplitEventInfo 的 json 结构保持一致
+type splitEventData struct {
+	Address  string         `json:"address"`
+	Height   int64          `json:"height"`
+	Balance  int64          `json:"balance"`
+	TxId     string         `json:"txId"`
+	SplitMap map[string]int `json:"splitMap"`
+	Wallet   *walletData    `json:"wallet"`
+}
+
+type walletData struct {
+	IntegralMap      map[string]int `json:"integralMap"`
+	SplitIntegralMap map[string]int `json:"splitIntegralMap"`
+}
+
+// exchangeEventData 与 service.ExchangeEventInfo 的 json 结构保持一致
+type exchangeEventData struct {
+	Address       string         `json:"address"`
+	Height        int64          `json:"height"`
+	Balance       int64          `json:"balance"`
+	ExchangeValue int64          `json:"exchangeValue"`
+	OrderId       string         `json:"orderId"`
+	TxId          string         `json:"txId"`
+	ExchangeMap   map[string]int `json:"exchangeMap"`
+}
+
+func NewMockClient(chainId string, addrs []string, interval time.Duration) *MockClient {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	return &MockClient{
+		chainId:  chainId,
+		addrs:    addrs,
+		interval: interval,
+		wallets:  make(map[string]*mockWallet),
+		txs:      make(map[string]*common.TransactionInfo),
+		block:    make(chan struct{}),
+	}
 }
 
 // SubscribeContractEvent 模拟实际的订阅方法
@@ -22,25 +114,35 @@ func (c *MockClient) SubscribeContractEvent(ctx context.Context, start, end int6
 		return nil, errors.New("无效的合约地址")
 	}
 
+	c.subscribe(start, contract, topic)
+
 	// 创建一个用于返回事件的 channel
 	eventChan := make(chan interface{})
 
-	// 启动一个 goroutine 来模拟事件的产生和发送
+	// 启动一个 goroutine 从起始高度回放并发送该订阅的事件
 	go func() {
 		// 确保在 goroutine 结束时关闭 channel，这是至关重要的
 		defer close(eventChan)
 
-		// 模拟发送5个事件
-		for i := 0; i < 5; i++ {
-			event := &Event{
-				Topic: "collect",
-				Data:  []byte(fmt.Sprintf("事件 %d 来自合约 %s", i+1, contract)),
+		// 订阅的游标：下一个待检查的事件序号
+		var next int
+		for {
+			evs, done, block := c.eventsSince(&next, start, end, contract, topic)
+			for _, ev := range evs {
+				select {
+				case <-ctx.Done(): // 如果外部上下文被取消，立即停止发送
+					return
+				case eventChan <- ev: // 发送事件
+				}
+			}
+			if done {
+				return
 			}
+
 			select {
-			case <-ctx.Done(): // 如果外部上下文被取消，立即停止发送
+			case <-ctx.Done():
 				return
-			case eventChan <- event: // 发送事件
-				time.Sleep(5 * time.Second) // 模拟事件之间的时间间隔
+			case <-block:
 			}
 		}
 	}()
@@ -48,9 +150,185 @@ func (c *MockClient) SubscribeContractEvent(ctx context.Context, start, end int6
 	return eventChan, nil
 }
 
-// 这是你提供的函数
-func ListenContractEvents(ctx context.Context, start, end int64, contract, topic string) (<-chan interface{}, error) {
-	// 假设 Client.cmClient 是 MockClient 的一个实例
-	var client = &MockClient{}
-	return client.SubscribeContractEvent(ctx, start, end, contract, topic)
+// GetCurrentBlockHeight 返回模拟的当前区块高度
+func (c *MockClient) GetCurrentBlockHeight() (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.height, nil
+}
+
+// GetTxByTxId 返回模拟产生过的交易
+func (c *MockClient) GetTxByTxId(txId string) (*common.TransactionInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tx, ok := c.txs[txId]
+	if !ok {
+		return nil, fmt.Errorf("tx %s not found", txId)
+	}
+
+	return tx, nil
+}
+
+// subscribe 登记订阅的 (合约, topic)，首次订阅时开始出块
+func (c *MockClient) subscribe(start int64, contract, topic string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// 尚未产生事件时，链从最早订阅的起始高度开始出块
+	if len(c.events) == 0 && uint64(start) > c.height+1 {
+		c.height = uint64(start) - 1
+	}
+
+	var found bool
+	for _, t := range c.topics {
+		if t.contract == contract && t.topic == topic {
+			found = true
+			break
+		}
+	}
+	if !found {
+		c.topics = append(c.topics, &mockTopic{contract: contract, topic: topic})
+	}
+
+	if !c.started {
+		c.started = true
+		go c.produce()
+	}
+}
+
+// produce 按间隔出块，mock 客户端与进程同生命周期
+func (c *MockClient) produce() {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		c.nextBlock()
+	}
+}
+
+// eventsSince 返回游标之后该订阅的事件，done 表示已到达结束高度，block 在下一次出块时关闭
+func (c *MockClient) eventsSince(next *int, start, end int64, contract, topic string) ([]*common.ContractEventInfo, bool, <-chan struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var evs []*common.ContractEventInfo
+	for ; *next < len(c.events); *next++ {
+		ev := c.events[*next]
+		if end >= 0 && int64(ev.BlockHeight) > end {
+			return evs, true, c.block
+		}
+		if int64(ev.BlockHeight) >= start && ev.ContractName == contract && ev.Topic == topic {
+			evs = append(evs, ev)
+		}
+	}
+
+	return evs, end >= 0 && int64(c.height) >= end, c.block
+}
+
+// nextBlock 出块，并为下一个 topic 与地址生成一个事件
+func (c *MockClient) nextBlock() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.height++
+	defer func() {
+		close(c.block)
+		c.block = make(chan struct{})
+	}()
+
+	if len(c.addrs) == 0 || len(c.topics) == 0 {
+		return
+	}
+
+	t := c.topics[c.seq%len(c.topics)]
+	addr := c.addrs[(c.seq/len(c.topics))%len(c.addrs)]
+	c.seq++
+
+	txId := fmt.Sprintf("mock_tx_%d_%d", c.height, c.seq)
+	data, ok := c.eventData(t.topic, addr, txId)
+	if !ok {
+		return
+	}
+
+	c.txs[txId] = &common.TransactionInfo{
+		BlockHeight:    c.height,
+		BlockTimestamp: time.Now().Unix(),
+	}
+	c.events = append(c.events, &common.ContractEventInfo{
+		BlockHeight:  c.height,
+		ChainId:      c.chainId,
+		Topic:        t.topic,
+		TxId:         txId,
+		ContractName: t.contract,
+		EventData:    []string{string(data)},
+	})
+}
+
+// eventData 按 topic 变更钱包并生成事件数据，钱包余额不足以拆分或兑换时本块不产生事件
+func (c *MockClient) eventData(topic, addr, txId string) ([]byte, bool) {
+	w, ok := c.wallets[addr]
+	if !ok {
+		w = new(mockWallet)
+		c.wallets[addr] = w
+	}
+	height := int64(c.height)
+
+	switch topic {
+	case splitTopic:
+		if w.unsplit == 0 {
+			return nil, false
+		}
+		w.unsplit--
+		w.split++
+
+		data, _ := json.Marshal(&splitEventData{
+			Address:  addr,
+			Height:   height,
+			Balance:  w.balance(),
+			TxId:     txId,
+			SplitMap: map[string]int{mockIntegralHash: 1},
+			Wallet: &walletData{
+				IntegralMap:      map[string]int{mockIntegralHash: int(w.unsplit)},
+				SplitIntegralMap: map[string]int{mockIntegralHash: int(w.split)},
+			},
+		})
+		return data, true
+
+	case exchangeTopic:
+		if w.balance() < mockExchangeValue {
+			return nil, false
+		}
+		// 先消耗未拆分积分，不足时再消耗已拆分积分
+		fromUnsplit := int64(mockExchangeValue)
+		if w.unsplit < fromUnsplit {
+			fromUnsplit = w.unsplit
+		}
+		w.unsplit -= fromUnsplit
+		w.split -= mockExchangeValue - fromUnsplit
+
+		data, _ := json.Marshal(&exchangeEventData{
+			Address:       addr,
+			Height:        height,
+			Balance:       w.balance(),
+			ExchangeValue: mockExchangeValue,
+			OrderId:       "mock_order_" + txId,
+			TxId:          txId,
+			ExchangeMap:   map[string]int{mockIntegralHash: mockExchangeValue},
+		})
+		return data, true
+
+	default:
+		w.unsplit += mockCollectValue
+
+		data, _ := json.Marshal(&collectEventData{
+			Address:     addr,
+			Height:      height,
+			Balance:     w.balance(),
+			ChangeValue: mockCollectValue,
+			TxId:        txId,
+		})
+		return data, true
+	}
 }
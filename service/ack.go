@@ -0,0 +1,113 @@
+package service
+
+// 接收方确认：开启后 sink 送达的记录保持已发送，接收方按同步记录 id 或交易 id 确认或拒绝
+// 已被认领（locked_by 非空）的已发送记录即可被确认，接收方在 sink 返回之前的确认同样有效，送达后不会被覆盖
+// 确认后标记为成功；拒绝与超时未确认均计入一次战略失败，退避后重新推送，达到最大重试次数后标记为失败
+import (
+	"chain-proxy/config"
+	"chain-proxy/db"
+	"chain-proxy/db/model"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"time"
+)
+
+const defaultAckTimeout = 5 * time.Minute
+
+// AckRequest 确认请求，id 与 txId 二选一
+// 按 txId 时需同时指定 userId，作用于该用户在该交易下所有待确认的记录，批量交易中其他用户的记录不受影响
+type AckRequest struct {
+	Id     int    `json:"id"`
+	TxId   string `json:"txId"`
+	UserId string `json:"userId"`
+	Reason string `json:"reason"` // 拒绝原因
+}
+
+// AckEvents 确认待确认的记录，返回被确认的记录数
+func AckEvents(req *AckRequest) (int64, error) {
+	query, err := awaitingAck(req)
+	if err != nil {
+		return 0, err
+	}
+
+	res := query.Updates(map[string]interface{}{
+		model.SyncStatusCol:   StatusSuccess,
+		model.RetryCountCol:   0,
+		model.ErrorMessageCol: "",
+		model.LockedByCol:     "",
+		model.LeaseUntilCol:   nil,
+	})
+
+	return res.RowsAffected, res.Error
+}
+
+// NackEvents 拒绝待确认的记录，返回被拒绝的记录数
+func NackEvents(req *AckRequest) (int64, error) {
+	query, err := awaitingAck(req)
+	if err != nil {
+		return 0, err
+	}
+
+	var srs []*model.SyncEventLog
+	err = query.Find(&srs).Error
+	if err != nil {
+		return 0, err
+	}
+
+	reason := req.Reason
+	if reason == "" {
+		reason = "rejected by receiver"
+	}
+
+	failures := make([]*pushFailure, len(srs))
+	for i, sr := range srs {
+		failures[i] = &pushFailure{sr: sr, reason: reason, policy: pushPolicyOf(sr.EventType)}
+	}
+	err = markPushFailed(failures)
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(len(srs)), nil
+}
+
+// awaitingAck 构造按 id 或 txId 查询待确认记录的条件，包括推送中尚未记录送达的记录
+func awaitingAck(req *AckRequest) (*gorm.DB, error) {
+	query := db.GetGormDb().
+		Table(model.TableSyncEventLog).
+		Where(model.SyncStatusCol+" = ? and "+model.LockedByCol+" <> ''", StatusSent)
+
+	switch {
+	case req.Id != 0:
+		return query.Where("id = ?", req.Id), nil
+	case req.TxId != "" && req.UserId != "":
+		return query.Where("tx_id = ? and user_id = ?", req.TxId, req.UserId), nil
+	case req.TxId != "":
+		return nil, errors.New("userId is required when acknowledging by txId")
+	default:
+		return nil, errors.New("id or txId is required")
+	}
+}
+
+// AckToken 返回确认接口的访问令牌，未开启确认时为空
+func AckToken() string {
+	if ac := config.GetConfigInstance().Ack; ac != nil && ac.Enabled {
+		return ac.Token
+	}
+
+	return ""
+}
+
+func ackEnabled() bool {
+	ac := config.GetConfigInstance().Ack
+	return ac != nil && ac.Enabled
+}
+
+// ackTimeout 返回确认超时，需大于 0
+func ackTimeout() time.Duration {
+	if ac := config.GetConfigInstance().Ack; ac != nil && ac.Timeout > 0 {
+		return time.Duration(ac.Timeout) * time.Second
+	}
+
+	return defaultAckTimeout
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"bytes"
+	"chain-proxy/config"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// alertMsg 告警通知内容
+type alertMsg struct {
+	Msg  string `json:"msg"`
+	Time int64  `json:"time"`
+}
+
+// raiseAlert 发出告警：打印日志，配置了通知地址时异步推送
+func raiseAlert(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	fmt.Printf("[ALERT] %s\n", msg)
+
+	ac := config.GetConfigInstance().Alert
+	if ac == nil || ac.WebhookUrl == "" {
+		return
+	}
+
+	go func(url string) {
+		body, err := json.Marshal(&alertMsg{Msg: msg, Time: time.Now().Unix()})
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		client := &http.Client{Timeout: 10 * time.Second}
+		resp, err := client.Post(url, "application/json", bytes.NewBuffer(body))
+		if err != nil {
+			fmt.Printf("send alert failed: %v\n", err)
+			return
+		}
+		_ = resp.Body.Close()
+	}(ac.WebhookUrl)
+}
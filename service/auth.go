@@ -56,6 +56,9 @@ func Auth(apiReq []byte) (*AuthResponse, error) {
 		Dcid:        req.Dcid,
 		Balance:     int64(walletinfo.Total),
 		BlockHeight: int64(walletinfo.BlockHeight),
+		// 余额连续性校验以授权时的钱包状态为起点
+		SyncedBalance: int64(walletinfo.Total),
+		SyncedHeight:  int64(walletinfo.BlockHeight),
 	}
 
 	err = db.GetGormDb().Table(model.TableUserAuth).Create(r).Error
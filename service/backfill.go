@@ -0,0 +1,157 @@
+package service
+
+import (
+	"chain-proxy/chain"
+	"chain-proxy/config"
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+)
+
+// BackfillRequest 按指定区块区间重新处理历史事件
+type BackfillRequest struct {
+	ChainId      string `json:"chainId"`
+	ContractName string `json:"contractName"`
+	Topic        string `json:"topic"`
+	Start        int64  `json:"start"`
+	End          int64  `json:"end"`
+	// 事件处理器名称，为空时使用订阅配置中的 handler
+	Handler string `json:"handler"`
+}
+
+// BackfillResult 回填结果统计
+type BackfillResult struct {
+	Inserted   int `json:"inserted"`   // 新写入的同步记录
+	Skipped    int `json:"skipped"`    // 无需同步的事件数据项
+	Duplicated int `json:"duplicated"` // 已存在的同步记录
+	Failed     int `json:"failed"`     // 处理失败的事件
+}
+
+// Backfill 以有界区间 [start, end] 订阅合约事件，并让每个事件走与实时监听相同的处理流程
+// 重复事件由同步记录的唯一键去重，回填不会推进监听进度
+func Backfill(ctx context.Context, req *BackfillRequest) (*BackfillResult, error) {
+	if req.Start < 0 || req.End < req.Start {
+		return nil, fmt.Errorf("invalid backfill range [%d, %d]", req.Start, req.End)
+	}
+
+	cc, err := findChain(req.ChainId)
+	if err != nil {
+		return nil, err
+	}
+
+	sub := findSubscription(cc, req.ContractName, req.Topic)
+	if req.Handler != "" {
+		sub.Handler = req.Handler
+	}
+
+	src, ok := chain.GetEventSource(cc.ChainId)
+	if !ok {
+		return nil, fmt.Errorf("event source of chain %s not registered", cc.ChainId)
+	}
+
+	// 回填区间必须已达到确认深度
+	if cc.ConfirmDepth > 0 {
+		head, err := src.GetCurrentBlockHeight()
+		if err != nil {
+			return nil, err
+		}
+		if req.End+cc.ConfirmDepth > int64(head) {
+			return nil, fmt.Errorf("end height %d not confirmed, current height %d, confirm depth %d", req.End, head, cc.ConfirmDepth)
+		}
+	}
+
+	l, err := NewEventListener(cc, src, sub)
+	if err != nil {
+		return nil, err
+	}
+	l.backfill = true
+
+	evCh, err := chain.ListenContractEvents(ctx, src, req.Start, req.End, sub.ContractName, sub.Topic)
+	if err != nil {
+		return nil, err
+	}
+
+	res := new(BackfillResult)
+	for {
+		select {
+		case er, ok := <-evCh:
+			if !ok {
+				fmt.Printf("backfill chain %s contract %s topic %s [%d, %d] done: %+v\n",
+					cc.ChainId, sub.ContractName, sub.Topic, req.Start, req.End, *res)
+				return res, nil
+			}
+
+			if er.Err != nil {
+				err = l.deadLetter(er.Raw, nil, errors.Wrap(er.Err, "convert contract event failed"))
+				if err != nil {
+					fmt.Println(err)
+				}
+				res.Failed++
+				continue
+			}
+
+			outcomes, err := l.handleContractEvent(er.Event)
+			if err != nil {
+				if isInvalidEvent(err) {
+					err = l.deadLetter(nil, er.Event, err)
+				}
+				if err != nil {
+					fmt.Println(err)
+				}
+				res.Failed++
+				continue
+			}
+
+			for _, outcome := range outcomes {
+				switch outcome {
+				case outcomeInserted:
+					res.Inserted++
+				case outcomeDuplicated:
+					res.Duplicated++
+				default:
+					res.Skipped++
+				}
+			}
+
+		case <-ctx.Done():
+			return res, ctx.Err()
+		}
+	}
+}
+
+// findChain 根据 chain id 查找应用链配置，chain id 为空时使用第一条链
+func findChain(chainId string) (*config.ChainClient, error) {
+	ccs := config.GetConfigInstance().ChainClients()
+	if len(ccs) == 0 {
+		return nil, errors.New("no chain configured")
+	}
+
+	if chainId == "" {
+		return ccs[0], nil
+	}
+
+	for _, cc := range ccs {
+		if cc.ChainId == chainId {
+			return cc, nil
+		}
+	}
+
+	return nil, fmt.Errorf("chain %s not configured", chainId)
+}
+
+// findSubscription 查找应用链上匹配合约与 topic 的订阅配置，未配置时返回使用 collect 处理器的订阅
+// 回填时指定的处理器需要与该 (合约, topic) 已注册的处理器一致
+func findSubscription(cc *config.ChainClient, contract, topic string) *config.Subscription {
+	for _, sub := range Subscriptions(cc) {
+		if (contract == "" || sub.ContractName == contract) && (topic == "" || sub.Topic == topic) {
+			s := *sub
+			return &s
+		}
+	}
+
+	return &config.Subscription{
+		ContractName: contract,
+		Topic:        topic,
+		Handler:      HandlerCollect,
+	}
+}
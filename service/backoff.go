@@ -0,0 +1,29 @@
+package service
+
+import (
+	"math/rand"
+	"time"
+)
+
+const (
+	resubscribeBaseBackoff = time.Second      // 重新订阅的初始等待时间
+	resubscribeMaxBackoff  = 60 * time.Second // 重新订阅的最大等待时间
+)
+
+// expBackoff 计算第 attempt 次（从 0 开始）重试前的等待时间
+// 指数增长并封顶于 max，再叠加 [0, d/2) 的随机抖动，避免多个任务同时重试
+func expBackoff(base, max time.Duration, attempt int) time.Duration {
+	d := base
+	for i := 0; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+
+	if half := int64(d / 2); half > 0 {
+		d += time.Duration(rand.Int63n(half))
+	}
+
+	return d
+}
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    time.Duration
+		max     time.Duration
+		attempt int
+		want    time.Duration // 抖动前的等待时间
+	}{
+		{name: "first attempt", base: time.Second, max: time.Minute, attempt: 0, want: time.Second},
+		{name: "doubles", base: time.Second, max: time.Minute, attempt: 3, want: 8 * time.Second},
+		{name: "capped", base: time.Second, max: time.Minute, attempt: 10, want: time.Minute},
+		{name: "base above max", base: 2 * time.Minute, max: time.Minute, attempt: 0, want: time.Minute},
+		{name: "large attempt", base: time.Second, max: time.Hour, attempt: 1000, want: time.Hour},
+		{name: "no jitter below 2ns", base: time.Nanosecond, max: time.Nanosecond, attempt: 1, want: time.Nanosecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				got := expBackoff(tt.base, tt.max, tt.attempt)
+				if got < tt.want || got >= tt.want+tt.want/2+1 {
+					t.Fatalf("expBackoff = %v, want in [%v, %v)", got, tt.want, tt.want+tt.want/2)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,315 @@
+package service
+
+// 批量送达：每个通道按事件数或等待时间凑批，单条推送即只有一条记录的批次，与批量推送走同一流程
+// 暂停检查、顺序检查、认领与送达成功的状态更新每批各一条语句，失败的记录一次更新计入战略失败
+// 同一用户每批只推送最早的一条，其余留到下一批，批内部分失败不会打乱用户内的推送顺序
+import (
+	"chain-proxy/config"
+	"chain-proxy/db"
+	"chain-proxy/db/model"
+	"chain-proxy/sink"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+const defaultBatchLinger = 200 * time.Millisecond
+
+// 批次序号，与实例标识一起作为认领标记，区分同一实例的不同批次
+var batchSeq uint64
+
+// batchConfig 返回批量送达配置与支持批量的 sink，未开启批量时返回 nil
+func batchConfig() (*config.Batch, sink.BatchSink) {
+	bs, ok := deliverSink.(sink.BatchSink)
+	if !ok {
+		return nil, nil
+	}
+
+	sc := config.GetConfigInstance().Sink
+	if sc == nil || sc.Batch == nil || sc.Batch.MaxCount <= 1 {
+		return nil, nil
+	}
+
+	return sc.Batch, bs
+}
+
+// runBatchLane 按批推送通道中的记录，未开启批量时每批一条，退出时未送达的批次保持待发送
+func (d *dispatcher) runBatchLane(ch chan *pushTask, bc *config.Batch) {
+	linger := defaultBatchLinger
+	if bc.Linger > 0 {
+		linger = time.Duration(bc.Linger) * time.Millisecond
+	}
+
+	var (
+		batch []*pushTask
+		timer = time.NewTimer(linger)
+	)
+	timer.Stop()
+
+	// 同一用户留到下一批的记录仍在排队，推送完成后才取消排队标记
+	flush := func() {
+		timer.Stop()
+		for len(batch) != 0 {
+			left := pushBatch(batch)
+			d.release(excludeTasks(batch, left)...)
+			batch = left
+		}
+	}
+
+	for {
+		select {
+		case t := <-ch:
+			if len(batch) == 0 {
+				timer.Reset(linger)
+			}
+			batch = append(batch, t)
+
+			if len(batch) >= bc.MaxCount {
+				flush()
+			}
+		case <-timer.C:
+			if len(batch) != 0 {
+				flush()
+			}
+		case <-d.ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// excludeTasks 返回 tasks 中不在 left 里的任务
+func excludeTasks(tasks, left []*pushTask) []*pushTask {
+	skip := make(map[*pushTask]bool, len(left))
+	for _, t := range left {
+		skip[t] = true
+	}
+
+	var done []*pushTask
+	for _, t := range tasks {
+		if !skip[t] {
+			done = append(done, t)
+		}
+	}
+
+	return done
+}
+
+// eventSize 估算推送信封序列化后的字节数
+func eventSize(ev *sink.Event) int {
+	b, _ := json.Marshal(ev)
+	return len(b)
+}
+
+// pushBatch 推送一批记录：认领、送达（含战术重试）、更新状态，返回留到下一批的记录
+// 被暂停、存在更早未完成记录或已被其他任务认领的记录保持原状态，不会留到下一批
+func pushBatch(tasks []*pushTask) []*pushTask {
+	bc, bs := batchConfig()
+
+	// 0. 同一用户只取通道中最早的一条
+	var (
+		batch   []*pushTask
+		userIds []string
+		users   = make(map[string]bool)
+		taken   = make(map[*pushTask]bool)
+	)
+	for _, t := range tasks {
+		if users[t.sr.UserId] {
+			continue
+		}
+		users[t.sr.UserId] = true
+		userIds = append(userIds, t.sr.UserId)
+		batch = append(batch, t)
+	}
+
+	// 1. 余额不连续的用户暂停推送，记录保持待发送，释放后再推送
+	var heldUsers []string
+	err := db.GetGormDb().
+		Table(model.TableUserAuth).
+		Where("user_id in ? and held = ?", userIds, true).
+		Pluck("user_id", &heldUsers).Error
+	if err != nil {
+		// 记录保持待发送，由重试调度重新推送
+		fmt.Printf("failed to check hold of %d users: %v\n", len(userIds), err)
+		return nil
+	}
+	held := make(map[string]bool, len(heldUsers))
+	for _, userId := range heldUsers {
+		held[userId] = true
+	}
+
+	// 2. 该用户更早的记录尚未完成时保持待发送，保证用户内的推送顺序
+	var ids []int
+	for _, t := range batch {
+		if !held[t.sr.UserId] {
+			ids = append(ids, t.sr.ID)
+		}
+	}
+	blocked, err := blockedByEarlier(ids)
+	if err != nil {
+		fmt.Printf("failed to check earlier events of %d events: %v\n", len(ids), err)
+		return nil
+	}
+
+	dcids, err := userDcids(userIds)
+	if err != nil {
+		fmt.Printf("failed to load dcid of %d users: %v\n", len(userIds), err)
+		return nil
+	}
+
+	// 3. 构造推送信封，超过字节上限的记录留到下一批，单条超过上限时单独成批
+	var (
+		evs      []*sink.Event
+		size     int
+		records  = make(map[int]*pushTask)
+		failures = make(map[int]error)
+	)
+	ids = ids[:0]
+	for _, t := range batch {
+		if held[t.sr.UserId] {
+			fmt.Printf("user %s is held, event id %d stays pending\n", t.sr.UserId, t.sr.ID)
+			taken[t] = true
+			continue
+		}
+		if blocked[t.sr.ID] {
+			fmt.Printf("user %s has earlier unfinished events, event id %d stays pending\n", t.sr.UserId, t.sr.ID)
+			taken[t] = true
+			continue
+		}
+
+		ev, err := newSinkEvent(t.sr, dcids[t.sr.UserId])
+		if err == nil && bc != nil && bc.MaxBytes > 0 {
+			n := eventSize(ev)
+			if len(ids) != 0 && size+n > bc.MaxBytes {
+				continue
+			}
+			size += n
+		}
+
+		taken[t] = true
+		records[t.sr.ID] = t
+		ids = append(ids, t.sr.ID)
+		if err != nil {
+			// 构造失败的记录认领后直接计入战略失败
+			failures[t.sr.ID] = err
+			continue
+		}
+		evs = append(evs, ev)
+	}
+
+	var left []*pushTask
+	for _, t := range tasks {
+		if !taken[t] {
+			left = append(left, t)
+		}
+	}
+	if len(ids) == 0 {
+		return left
+	}
+
+	// 4. 以本批的认领标记认领仍为待发送的记录，避免监听任务与重试调度重复推送
+	// 认领时写入租约，进程中途退出时由回收任务在租约到期后恢复为待发送
+	token := fmt.Sprintf("%s#%d", instanceId, atomic.AddUint64(&batchSeq, 1))
+	err = db.GetGormDb().
+		Table(model.TableSyncEventLog).
+		Where("id in ? and "+model.SyncStatusCol+" = ?", ids, StatusPending).
+		Updates(map[string]interface{}{
+			model.SyncStatusCol:  StatusSent,
+			model.LockedByCol:    token,
+			model.LeaseUntilCol:  time.Now().Add(leaseDuration()),
+			model.DeliveredAtCol: nil,
+		}).Error
+	if err != nil {
+		fmt.Printf("failed to mark %d events as sent: %v\n", len(ids), err)
+		return left
+	}
+
+	var claimed []int
+	err = db.GetGormDb().
+		Table(model.TableSyncEventLog).
+		Where(model.LockedByCol+" = ? and "+model.SyncStatusCol+" = ?", token, StatusSent).
+		Pluck("id", &claimed).Error
+	if err != nil {
+		// 认领的记录在租约到期后由回收任务恢复为待发送
+		fmt.Printf("failed to load claimed events of batch %s: %v\n", token, err)
+		return left
+	}
+	if len(claimed) != len(ids) {
+		fmt.Printf("%d of %d events are not pending, claimed by others\n", len(ids)-len(claimed), len(ids))
+	}
+
+	isClaimed := make(map[int]bool, len(claimed))
+	for _, id := range claimed {
+		isClaimed[id] = true
+	}
+	var pending []*sink.Event
+	for _, ev := range evs {
+		if isClaimed[ev.Id] {
+			pending = append(pending, ev)
+		}
+	}
+
+	// 5. 进入内部的“战术重试”循环，每次只重新送达上一次失败的事件
+	var delivered []*sink.Event
+	for attempt := 1; attempt <= 3 && len(pending) != 0; attempt++ {
+		errs := deliverEvents(bs, pending)
+
+		var retry []*sink.Event
+		for i, ev := range pending {
+			if errs[i] == nil {
+				delivered = append(delivered, ev)
+				delete(failures, ev.Id)
+				continue
+			}
+			failures[ev.Id] = errs[i]
+			retry = append(retry, ev)
+		}
+		pending = retry
+
+		if len(pending) != 0 && attempt < 3 {
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+
+	// 6. 根据内部重试循环的结果，送达与失败的记录各一次更新最终状态
+	if len(delivered) != 0 {
+		err = markDelivered(delivered)
+		if err != nil {
+			fmt.Printf("events delivered, but failed to mark %d events as success: %v\n", len(delivered), err)
+		}
+	}
+
+	var failed []*pushFailure
+	for id, handleErr := range failures {
+		if !isClaimed[id] {
+			continue
+		}
+		t := records[id]
+		failed = append(failed, &pushFailure{sr: t.sr, reason: handleErr.Error(), policy: t.policy})
+		fmt.Printf("event id %d failed in batch %s: %v\n", id, token, handleErr)
+	}
+	err = markPushFailed(failed)
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	fmt.Printf("[Strategic] batch %s delivered %d of %d events.\n", token, len(delivered), len(claimed))
+
+	return left
+}
+
+// deliverEvents 送达一批推送信封，返回与 evs 一一对应的错误；未开启批量时逐条送达
+func deliverEvents(bs sink.BatchSink, evs []*sink.Event) []error {
+	if bs != nil {
+		return bs.DeliverBatch(context.Background(), evs)
+	}
+
+	errs := make([]error, len(evs))
+	for i, ev := range evs {
+		errs[i] = deliverSink.Deliver(context.Background(), ev)
+	}
+
+	return errs
+}
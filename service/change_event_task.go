@@ -6,154 +6,361 @@ import (
 	"chain-proxy/config"
 	"chain-proxy/db"
 	"chain-proxy/db/model"
+	"chain-proxy/sink"
 	"context"
-	"database/sql"
-	"encoding/json"
 	"fmt"
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
-func HandleCollectEvent(ctx context.Context) error {
-	var (
-		err error
-	)
+// eventOutcome 单个事件经过处理流程后的结果
+type eventOutcome int
+
+const (
+	outcomeSkipped    eventOutcome = iota // 无需同步（未授权、授权前发生等）
+	outcomeInserted                       // 新写入同步记录
+	outcomeDuplicated                     // 同步记录已存在
+)
+
+// EventListener 单条应用链上单个合约事件订阅的监听任务，事件源由外部注入（真实链或 mock）
+type EventListener struct {
+	chain      *config.ChainClient
+	source     chain.EventSource
+	sub        *config.Subscription
+	backfill   bool  // 回填模式下不推进监听进度
+	reconnects int64 // 重新订阅的累计次数
+}
+
+func NewEventListener(cc *config.ChainClient, source chain.EventSource, sub *config.Subscription) (*EventListener, error) {
+	if cc == nil || source == nil {
+		return nil, errors.New("chain config and event source are required")
+	}
+
+	if sub == nil || sub.ContractName == "" || sub.Topic == "" {
+		return nil, errors.New("subscription contract name and topic are required")
+	}
+
+	err := registerSubscription(sub)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EventListener{
+		chain:  cc,
+		source: source,
+		sub:    sub,
+	}, nil
+}
+
+// Subscriptions 返回应用链配置的订阅列表，未配置时兼容旧的单合约配置
+func Subscriptions(cc *config.ChainClient) []*config.Subscription {
+	if len(cc.Subscriptions) != 0 {
+		return cc.Subscriptions
+	}
+
+	return []*config.Subscription{
+		{
+			ContractName: cc.ContractName,
+			Topic:        CarbonIntegralChangeTopic,
+			Handler:      HandlerCollect,
+		},
+	}
+}
+
+// Run 受监督的订阅循环：
+// 事件通道关闭或订阅失败时，根据已持久化的进度重新计算起始高度，并以指数退避 + 抖动的方式重新订阅
+func (l *EventListener) Run(ctx context.Context) error {
+	untrack := trackListener(l)
+	defer untrack()
+
+	var attempt int
+
+	for {
+		received, err := l.listen(ctx)
+		if ctx.Err() != nil {
+			fmt.Printf("chain %s contract %s topic %s recv ctx cancel signal, listen cc event task will close\n", l.chain.ChainId, l.sub.ContractName, l.sub.Topic)
+			return ctx.Err()
+		}
+
+		// 本轮订阅收到过事件，说明连接曾恢复正常，退避从头计算
+		if received {
+			attempt = 0
+		}
+
+		wait := expBackoff(resubscribeBaseBackoff, resubscribeMaxBackoff, attempt)
+		attempt++
+		n := atomic.AddInt64(&l.reconnects, 1)
+		fmt.Printf("listen chain %s contract %s topic %s interrupted: %v, resubscribe #%d after %v\n", l.chain.ChainId, l.sub.ContractName, l.sub.Topic, err, n, wait)
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			fmt.Printf("chain %s contract %s topic %s recv ctx cancel signal, listen cc event task will close\n", l.chain.ChainId, l.sub.ContractName, l.sub.Topic)
+			return ctx.Err()
+		}
+	}
+}
+
+// listen 执行一次订阅并消费事件，直到通道关闭、订阅失败或 ctx 取消
+// 先以有界区间订阅到当前链头（追块），区间订阅结束说明其中的事件都已收到，再从链头之后实时订阅
+// received 表示本轮订阅是否收到过事件
+func (l *EventListener) listen(ctx context.Context) (received bool, err error) {
+	// 本轮订阅结束时取消 sdk 订阅与转换协程，重新订阅时不会遗留仍在读取链上事件的订阅
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// 每次订阅都从已持久化的进度重新计算起始高度
+	start, err := getListenStart(l.chain, l.sub)
+	if err != nil {
+		return false, err
+	}
 
-	// 监听合约事件
-	start, err := getListenStart()
+	head, err := l.source.GetCurrentBlockHeight()
 	if err != nil {
-		return err
+		return false, err
+	}
+
+	// 事件先进入确认深度缓冲区，达到确认深度后再处理
+	cf := newConfirmer(l.source, l.chain.ConfirmDepth)
+
+	if start <= int64(head) {
+		received, err = l.consume(ctx, cf, start, int64(head))
+		if err != nil {
+			return received, err
+		}
+		start = int64(head) + 1
 	}
 
-	evCh, err := chain.ListenContractEvents(ctx, start, -1, config.GetConfigInstance().ChainClient.ContractName, CarbonIntegralChangeTopic)
+	live, err := l.consume(ctx, cf, start, -1)
+	return received || live, err
+}
+
+// consume 订阅 [start, end] 区间的事件并处理，end < 0 时为实时订阅
+// 区间订阅的通道关闭表示区间内的事件都已收到；实时订阅在两次空闲检查之间没有收到事件时，
+// 视为上一次检查时链头及以下的事件都已收到，据此推进没有事件的订阅的监听进度
+func (l *EventListener) consume(ctx context.Context, cf *confirmer, start, end int64) (received bool, err error) {
+	evCh, err := chain.ListenContractEvents(ctx, l.source, start, end, l.sub.ContractName, l.sub.Topic)
 	if err != nil {
-		return err
+		return false, err
 	}
 
+	ticker := time.NewTicker(confirmPollInterval)
+	defer ticker.Stop()
+
+	// 上一次空闲检查时的链头高度，收到事件后重新计算
+	idleHead := int64(-1)
+
 	for {
 		select {
 		case res, ok := <-evCh:
 			if !ok {
-				return errors.New("event channel closed")
+				if end >= 0 {
+					return received, l.settle(cf, end)
+				}
+				return received, errors.New("event channel closed")
 			}
 
-			if res == nil {
-				err = errors.New("nil event received")
-				fmt.Println(err)
+			received = true
+			idleHead = -1
+			if res.Err != nil {
+				err = l.deadLetter(res.Raw, nil, errors.Wrap(res.Err, "convert contract event failed"))
+				if err != nil {
+					return received, err
+				}
 				continue
 			}
 
-			err = handleContractEvent(res)
+			cf.push(res.Event)
+			err = l.handleConfirmed(cf)
 			if err != nil {
-				fmt.Println(err)
+				return received, err
+			}
+
+		case <-ticker.C:
+			if cf.pending() != 0 {
+				err = l.handleConfirmed(cf)
+				if err != nil {
+					return received, err
+				}
+			}
+			if end >= 0 {
+				continue
 			}
 
+			if idleHead >= 0 {
+				err = l.settle(cf, idleHead)
+				if err != nil {
+					return received, err
+				}
+			}
+			head, err := l.source.GetCurrentBlockHeight()
+			if err != nil {
+				fmt.Printf("chain %s get current block height failed: %v\n", l.chain.ChainId, err)
+				idleHead = -1
+				continue
+			}
+			idleHead = int64(head)
+
 		case <-ctx.Done():
-			fmt.Printf("collect events recv ctx cancel signal, listen cc event task will close\n")
-			return ctx.Err()
+			return received, ctx.Err()
 		}
 	}
 }
 
-func getListenStart() (int64, error) {
-	var start sql.NullInt64
-	// 监听的起始高度
-	// Q: 是否需要区分 collect or exchange？
-	// A: 不需要区分，因为log表中的数据记录的block height有两个作用：
-	//   a1: max height 是为了减少冗余监听，始终以最新的变化为起始进行监听；
-	//   a2: 每个新插入到该表中的记录都是以auth中已授权用户的高度为准进行save，即始终会记录授权后该用户的余额变动；
-
-	err := db.GetGormDb().
-		Table(model.TableSyncEventLog).
-		Select("max(block_height)").
-		Where("contract_name = ?", config.GetConfigInstance().ChainClient.ContractName).
-		Scan(&start).Error
-	if err != nil {
-		fmt.Println(err)
-		return 0, err
+// settle 高度 height 及以下的事件都已收到时推进监听进度，确认深度缓冲区中尚未处理的事件除外，
+// 随后校验应用链上所有订阅都已越过的记录
+func (l *EventListener) settle(cf *confirmer, height int64) error {
+	if oldest, ok := cf.oldest(); ok && oldest-1 < height {
+		height = oldest - 1
 	}
 
-	if start.Valid {
-		return start.Int64, nil
+	if !l.backfill {
+		err := advanceCheckpoint(db.GetGormDb(), l.chain.ChainId, l.sub.ContractName, l.sub.Topic, height)
+		if err != nil {
+			return err
+		}
 	}
 
-	start.Int64 = config.GetConfigInstance().ChainClient.DefaultHeight
-
-	return start.Int64, nil
+	return validateSettled(l.chain)
 }
 
-func handleContractEvent(ev interface{}) error {
-	bytes, err := json.Marshal(ev)
+// handleConfirmed 处理确认深度缓冲区中已确认的事件
+// 解析或校验失败的事件进入死信；其余错误（如数据库异常）返回并放弃本批剩余事件，
+// 由 Run 退避后从已持久化的进度重新订阅，避免后续事件推进监听进度而跳过失败的区块
+func (l *EventListener) handleConfirmed(cf *confirmer) error {
+	evs, err := cf.release()
 	if err != nil {
-		return errors.Wrap(err, "failed to marshal unknown event")
+		// 查询链头失败时事件保留在缓冲区中，下次再确认
+		fmt.Printf("chain %s get current block height failed: %v\n", l.chain.ChainId, err)
+		return nil
 	}
 
-	evInfo := new(Event)
-	err = json.Unmarshal(bytes, evInfo)
-	if err != nil {
-		return errors.Wrap(err, "failed to unmarshal unknown event into canonical Event struct")
-	}
+	for _, evInfo := range evs {
+		_, err = l.handleContractEvent(evInfo)
+		if err == nil {
+			continue
+		}
 
-	if len(evInfo.EventData) == 0 {
-		return errors.New("event data is empty")
+		if !isInvalidEvent(err) {
+			return errors.Wrapf(err, "handle event of tx %s at height %d failed", evInfo.TxId, evInfo.BlockHeight)
+		}
+
+		err = l.deadLetter(nil, evInfo, err)
+		if err != nil {
+			return err
+		}
 	}
 
-	evData := new(CollectEventInfo)
-	err = json.Unmarshal([]byte(evInfo.EventData[0]), evData)
-	if err != nil {
-		return errors.Wrap(err, "failed to unmarshal event data into AddCarbonIntegralBatchRequest struct")
+	return nil
+}
+
+// handleContractEvent 事件处理流程：按 (合约, topic) 分发给处理器解析、校验，落库并推进监听进度，校验余额连续性后按顺序推送
+// 同步记录依赖唯一键（chain_id, tx_id, event_index, sub_index）去重，同一事件重复处理是幂等的
+// 返回 EventData 中每一项的处理结果，没有处理器的事件以已忽略状态记录
+func (l *EventListener) handleContractEvent(evInfo *chain.ContractEvent) ([]eventOutcome, error) {
+	// 订阅已限定合约，事件中的合约名可能与配置不一致（如地址形式），以订阅配置为准
+	h, ok := LookupHandler(l.sub.ContractName, evInfo.Topic)
+	if !ok {
+		return nil, l.handleUnknownEvent(evInfo)
 	}
 
-	// 查询是否已授权
-	var uar = new(model.UserAuth)
-	err = db.GetGormDb().
-		Table(model.TableUserAuth).
-		Select("*").
-		Where("addr = ?", evData.Address).
-		Scan(&uar).Error
+	entries, err := h.Decode(evInfo)
 	if err != nil {
-		return err
+		return nil, &invalidEventError{err: err}
 	}
 
-	if uar.ID == 0 {
-		// 说明未授权
-		fmt.Printf("user %s has not auth", evData.Address)
-		return nil
+	srs := make([]*model.SyncEventLog, len(entries))
+	for i, entry := range entries {
+		uar, err := h.validate(entry)
+		if err != nil {
+			return nil, err
+		}
+		if uar == nil {
+			continue
+		}
+
+		sr, err := h.buildRecord(evInfo, entry, uar)
+		if err != nil {
+			return nil, &invalidEventError{err: err}
+		}
+		sr.ChainId = l.chain.ChainId
+		sr.ContractName = l.sub.ContractName
+		sr.EventIndex = evInfo.EventIndex
+		sr.SubIndex = i
+		srs[i] = sr
 	}
 
-	if uar.BlockHeight > evData.Height {
-		// 该事件在余额授权同步之前发生，属于无效事件
-		fmt.Printf("this event info is invalid %v", evData)
-		return nil
+	outcomes := make([]eventOutcome, len(srs))
+	// 同步记录与监听进度在同一事务中落库，保证重启后不会遗漏或跳过区块
+	err = db.GetGormDb().Transaction(func(tx *gorm.DB) error {
+		for i, sr := range srs {
+			if sr == nil {
+				outcomes[i] = outcomeSkipped
+				continue
+			}
+
+			inserted, err := h.persist(tx, sr)
+			if err != nil {
+				return err
+			}
+
+			outcomes[i] = outcomeDuplicated
+			if inserted {
+				outcomes[i] = outcomeInserted
+			}
+		}
+
+		return l.advanceCheckpoint(tx, evInfo)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	sr := &model.SyncEventLog{
-		UserId:       uar.UserId,
-		BlockHeight:  evData.Height,
-		BalanceAfter: evData.Balance,
-		ChangeValue:  evData.ChangeValue,
-		Topic:        CarbonIntegralChangeTopic,
-		TxId:         evData.TxId,
-		ContractName: config.GetConfigInstance().ChainClient.ContractName,
-		SyncStatus:   int(StatusPending),
-		RetryCount:   0,
+	for _, outcome := range outcomes {
+		if outcome == outcomeInserted {
+			notifyInserted()
+			break
+		}
 	}
 
-	err = db.GetGormDb().
-		Table(model.TableSyncEventLog).
-		Clauses(clause.OnConflict{
-			DoNothing: true,
-		}).
-		Create(sr).Error
+	// 新写入的记录在所有订阅都越过其高度后按区块顺序校验余额连续性，校验后分发推送
+	// 校验失败不影响事件落库，未校验的记录在下一次校验时处理
+	err = validateSettled(l.chain)
 	if err != nil {
-		return err
+		fmt.Printf("validate settled events of chain %s failed: %v\n", l.chain.ChainId, err)
 	}
 
-	err = pushEvent(sr.ID, ev)
+	return outcomes, nil
+}
+
+// handleUnknownEvent 记录没有注册处理器的事件并推进监听进度
+func (l *EventListener) handleUnknownEvent(evInfo *chain.ContractEvent) error {
+	fmt.Printf("no handler registered for contract %s topic %s, tx %s recorded as ignored\n", l.sub.ContractName, evInfo.Topic, evInfo.TxId)
 
-	return nil
+	return db.GetGormDb().Transaction(func(tx *gorm.DB) error {
+		ev := *evInfo
+		ev.ChainId = l.chain.ChainId
+		ev.ContractName = l.sub.ContractName
+		_, err := recordUnknownEvent(tx, &ev)
+		if err != nil {
+			return err
+		}
+
+		return l.advanceCheckpoint(tx, evInfo)
+	})
+}
+
+// advanceCheckpoint 推进当前订阅的监听进度，回填模式下不推进
+func (l *EventListener) advanceCheckpoint(tx *gorm.DB, evInfo *chain.ContractEvent) error {
+	if l.backfill {
+		return nil
+	}
+
+	return advanceCheckpoint(tx, l.chain.ChainId, l.sub.ContractName, l.sub.Topic, evInfo.BlockHeight)
 }
 
 // 3种方式：
@@ -161,64 +368,127 @@ func handleContractEvent(ev interface{}) error {
 // 2. 主动调用某个接口方法，将数据传送过去；
 // 3. 定时任务间隔获取数据库数据并传送；
 // pushEvent: Combined Tactical and Strategic Retry Logic
-func pushEvent(id int, ev interface{}) error {
-	// 1. 标记任务开始处理 (乐观更新)
-	err := db.GetGormDb().
-		Table(model.TableSyncEventLog).
-		Where("id = ?", id).
-		Update(model.SyncStatusCol, StatusSent).Error
-	if err != nil {
-		return fmt.Errorf("failed to mark event as sent for id %d: %w", id, err)
+// 推送内容为同步记录本身，event_payload 中携带各事件类型的附加数据（如拆分事件的两类积分）
+// 单条推送即只有一条记录的批次，与批量推送共用认领、战术重试与状态更新的流程
+func pushEvent(sr *model.SyncEventLog, policy PushPolicy) {
+	pushBatch([]*pushTask{{sr: sr, policy: policy}})
+}
+
+// markDelivered 一条语句更新 sink 送达后的状态，送达数币链时按记录回填数币链上的交易 id
+func markDelivered(evs []*sink.Event) error {
+	ids := make([]int, len(evs))
+	txIds := make(map[int]interface{}, len(evs))
+	var withTxId bool
+	for i, ev := range evs {
+		ids[i] = ev.Id
+		txIds[ev.Id] = ev.TargetTxId
+		withTxId = withTxId || ev.TargetTxId != ""
 	}
 
-	// 2. 进入内部的“战术重试”循环
-	var handleErr error
-	for attempt := 1; attempt <= 3; attempt++ {
-		handleErr = mockHandleEvent(ev)
-		if handleErr == nil {
-			break // 跳出循环
-		}
-		if attempt < 3 {
-			time.Sleep(50 * time.Millisecond)
-		}
+	var targetTxId interface{} = ""
+	if withTxId {
+		targetTxId = caseById(ids, txIds)
 	}
 
-	// 3. 根据内部重试循环的结果，更新最终状态
-	tx := db.GetGormDb().
+	updates := map[string]interface{}{
+		model.SyncStatusCol:  StatusSuccess,
+		model.RetryCountCol:  0,
+		model.TargetTxIdCol:  targetTxId,
+		model.LockedByCol:    "",
+		model.LeaseUntilCol:  nil,
+		model.DeliveredAtCol: time.Now(),
+	}
+	query := db.GetGormDb().
 		Table(model.TableSyncEventLog).
-		Where("id = ?", id)
-
-	if handleErr == nil {
-		fmt.Printf("[Strategic] Event id %d handled successfully.", id)
-		updates := map[string]interface{}{
-			model.SyncStatusCol: StatusSuccess,
-			model.RetryCountCol: 0,
-		}
-		err = tx.Updates(updates).Error
-		if err != nil {
-			return fmt.Errorf("event handled, but failed to mark as success for id %d: %w", id, err)
+		Where("id in ?", ids)
+	if ackEnabled() {
+		// 需要接收方确认时保持已发送，租约延长至确认超时，超时未确认由租约回收任务重新推送
+		// 推送过程中已被确认的记录保持成功，只回填交易 id；已被拒绝的记录等待重试，不做更新
+		updates = map[string]interface{}{
+			model.TargetTxIdCol:   targetTxId,
+			model.ErrorMessageCol: "",
+			model.LeaseUntilCol: gorm.Expr("CASE WHEN "+model.SyncStatusCol+" = ? THEN ? END",
+				StatusSent, time.Now().Add(ackTimeout())),
+			model.DeliveredAtCol: time.Now(),
 		}
+		query = query.Where(model.SyncStatusCol+" in ?", []SyncStatus{StatusSent, StatusSuccess})
+	}
+
+	return query.Updates(updates).Error
+}
+
+// pushFailure 一条记录的一次战略失败
+type pushFailure struct {
+	sr     *model.SyncEventLog
+	reason string
+	policy PushPolicy
+}
+
+// markPushFailed 记录一次战略失败：恢复为待发送等待重试调度在退避后重新推送，达到最大重试次数后标记为失败
+// 只作用于已发送的记录，期间已被确认为成功的记录不受影响；一批失败的记录共两条语句
+func markPushFailed(failures []*pushFailure) error {
+	if len(failures) == 0 {
 		return nil
 	}
 
-	// a. 原子地增加“战略失败”计数器
-	updateResult := tx.Update(model.RetryCountCol, gorm.Expr(model.RetryCountCol, " + 1"))
-	if updateResult.Error != nil {
-		return fmt.Errorf("failed to increment strategic failure count for id %d: %w", id, updateResult.Error)
+	now := time.Now()
+	ids := make([]int, len(failures))
+	reasons := make(map[int]interface{}, len(failures))
+	nextRetryAt := make(map[int]interface{}, len(failures))
+	maxAttempts := make(map[int]interface{}, len(failures))
+	for i, f := range failures {
+		id := f.sr.ID
+		ids[i] = id
+		reasons[id] = f.reason
+		nextRetryAt[id] = now.Add(retryBackoff(f.sr.RetryCount))
+		maxAttempts[id] = f.policy.maxAttempts()
 	}
 
-	// b. 检查是否达到战略失败的阈值
-	err = tx.Where(model.RetryCountCol+" >= ?", 3).
+	// a. 原子地增加“战略失败”计数器，记录失败原因，并恢复为待发送
+	res := db.GetGormDb().
+		Table(model.TableSyncEventLog).
+		Where("id in ? and "+model.SyncStatusCol+" = ?", ids, StatusSent).
+		Updates(map[string]interface{}{
+			model.SyncStatusCol:   StatusPending,
+			model.RetryCountCol:   gorm.Expr(model.RetryCountCol + " + 1"),
+			model.ErrorMessageCol: caseById(ids, reasons),
+			model.NextRetryAtCol:  caseById(ids, nextRetryAt),
+			model.LockedByCol:     "",
+			model.LeaseUntilCol:   nil,
+			model.DeliveredAtCol:  nil,
+		})
+	if res.Error != nil {
+		return fmt.Errorf("failed to increment strategic failure count for %d events: %w", len(ids), res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return nil
+	}
+
+	// b. 检查是否达到战略失败的阈值，各记录按所属处理器的最大重试次数判断
+	err := db.GetGormDb().
+		Table(model.TableSyncEventLog).
+		Where("id in ? and "+model.SyncStatusCol+" = ?", ids, StatusPending).
+		Where(model.RetryCountCol+" >= ?", caseById(ids, maxAttempts)).
 		Update(model.SyncStatusCol, StatusFailed).Error
 	if err != nil {
-		return fmt.Errorf("failed to mark event as failed after reaching max strategic retries for id %d: %w", id, err)
+		return fmt.Errorf("failed to mark events as failed after reaching max strategic retries: %w", err)
 	}
 
-	return fmt.Errorf("all %d tactical retries failed: %w", 3, handleErr)
-}
-
-func mockHandleEvent(ev interface{}) error {
 	return nil
 }
 
-// 积分拆分的事件怎么处理？
+// caseById 构造按记录 id 取值的 CASE 表达式，用于一条语句为多条记录更新不同的值
+func caseById(ids []int, values map[int]interface{}) clause.Expr {
+	var (
+		sql  strings.Builder
+		vars = make([]interface{}, 0, 2*len(ids))
+	)
+	sql.WriteString("(CASE id")
+	for _, id := range ids {
+		sql.WriteString(" WHEN ? THEN ?")
+		vars = append(vars, id, values[id])
+	}
+	sql.WriteString(" END)")
+
+	return gorm.Expr(sql.String(), vars...)
+}
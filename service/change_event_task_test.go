@@ -0,0 +1,50 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCaseById(t *testing.T) {
+	tests := []struct {
+		name     string
+		ids      []int
+		values   map[int]interface{}
+		wantSQL  string
+		wantVars []interface{}
+	}{
+		{
+			name:     "single",
+			ids:      []int{7},
+			values:   map[int]interface{}{7: "a"},
+			wantSQL:  "(CASE id WHEN ? THEN ? END)",
+			wantVars: []interface{}{7, "a"},
+		},
+		{
+			name:     "keeps id order",
+			ids:      []int{3, 1, 2},
+			values:   map[int]interface{}{1: 10, 2: 20, 3: 30},
+			wantSQL:  "(CASE id WHEN ? THEN ? WHEN ? THEN ? WHEN ? THEN ? END)",
+			wantVars: []interface{}{3, 30, 1, 10, 2, 20},
+		},
+		{
+			name:     "missing value",
+			ids:      []int{1, 2},
+			values:   map[int]interface{}{1: "a"},
+			wantSQL:  "(CASE id WHEN ? THEN ? WHEN ? THEN ? END)",
+			wantVars: []interface{}{1, "a", 2, nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := caseById(tt.ids, tt.values)
+			if got.SQL != tt.wantSQL {
+				t.Fatalf("SQL = %q, want %q", got.SQL, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(got.Vars, tt.wantVars) {
+				t.Fatalf("Vars = %v, want %v", got.Vars, tt.wantVars)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"chain-proxy/config"
+	"chain-proxy/db"
+	"chain-proxy/db/model"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"math"
+)
+
+// getListenStart 获取监听的起始高度
+// 以 listener_checkpoint 中记录的已处理高度为准，未记录时使用订阅配置的 StartHeight，再退回到 DefaultHeight
+// 已处理高度所在的区块会被重新监听一次，重复事件由 sync_event_log 的唯一键去重
+func getListenStart(cc *config.ChainClient, sub *config.Subscription) (int64, error) {
+	var cp = new(model.ListenerCheckpoint)
+	err := db.GetGormDb().
+		Table(model.TableListenerCheckpoint).
+		Select("*").
+		Where("chain_id = ? and contract_name = ? and topic = ?",
+			cc.ChainId,
+			sub.ContractName,
+			sub.Topic).
+		Scan(cp).Error
+	if err != nil {
+		fmt.Println(err)
+		return 0, err
+	}
+
+	if cp.ID != 0 {
+		return cp.BlockHeight, nil
+	}
+
+	if sub.StartHeight > 0 {
+		return sub.StartHeight, nil
+	}
+
+	return cc.DefaultHeight, nil
+}
+
+// advanceCheckpoint 在事务 tx 中推进监听进度，高度只增不减
+func advanceCheckpoint(tx *gorm.DB, chainId, contract, topic string, height int64) error {
+	cp := &model.ListenerCheckpoint{
+		ChainId:      chainId,
+		ContractName: contract,
+		Topic:        topic,
+		BlockHeight:  height,
+	}
+
+	return tx.Table(model.TableListenerCheckpoint).
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "chain_id"}, {Name: "contract_name"}, {Name: "topic"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"block_height": gorm.Expr("GREATEST(block_height, VALUES(block_height))"),
+				"updated_at":   gorm.Expr("VALUES(updated_at)"),
+			}),
+		}).
+		Create(cp).Error
+}
+
+// settledHeight 返回应用链所有订阅中最低的监听进度，该高度及以下的事件都已落库
+// 尚无监听进度的订阅以起始高度的前一个区块为准；同一区块内不同 topic 的事件没有先后之分
+func settledHeight(cc *config.ChainClient) (int64, error) {
+	var cps []*model.ListenerCheckpoint
+	err := db.GetGormDb().
+		Table(model.TableListenerCheckpoint).
+		Where("chain_id = ?", cc.ChainId).
+		Find(&cps).Error
+	if err != nil {
+		return 0, err
+	}
+
+	heights := make(map[string]int64, len(cps))
+	for _, cp := range cps {
+		heights[cp.ContractName+"/"+cp.Topic] = cp.BlockHeight
+	}
+
+	settled := int64(math.MaxInt64)
+	for _, sub := range Subscriptions(cc) {
+		h, ok := heights[sub.ContractName+"/"+sub.Topic]
+		if !ok {
+			h = cc.DefaultHeight - 1
+			if sub.StartHeight > 0 {
+				h = sub.StartHeight - 1
+			}
+		}
+		if h < settled {
+			settled = h
+		}
+	}
+
+	return settled, nil
+}
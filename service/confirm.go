@@ -0,0 +1,82 @@
+package service
+
+import (
+	"chain-proxy/chain"
+	"time"
+)
+
+// confirmPollInterval 存在待确认事件时查询链头高度的间隔
+const confirmPollInterval = 2 * time.Second
+
+// confirmer 确认深度缓冲区
+// 事件按到达顺序缓存，直到链头高度超过事件所在高度 depth 个区块后才会被释放处理，
+// 避免节点追块或回滚时替换掉的区块被同步到接收方
+type confirmer struct {
+	source chain.EventSource
+	depth  int64
+	queue  []*chain.ContractEvent
+	head   int64 // 最近一次查询到的链头高度
+}
+
+func newConfirmer(source chain.EventSource, depth int64) *confirmer {
+	return &confirmer{
+		source: source,
+		depth:  depth,
+	}
+}
+
+// push 缓存一个待确认的事件
+func (c *confirmer) push(ev *chain.ContractEvent) {
+	c.queue = append(c.queue, ev)
+}
+
+// pending 返回待确认的事件数量
+func (c *confirmer) pending() int {
+	return len(c.queue)
+}
+
+// oldest 返回缓冲区中最早的事件所在高度
+func (c *confirmer) oldest() (int64, bool) {
+	if len(c.queue) == 0 {
+		return 0, false
+	}
+
+	return c.queue[0].BlockHeight, true
+}
+
+// release 按顺序取出已达到确认深度的事件
+func (c *confirmer) release() ([]*chain.ContractEvent, error) {
+	if len(c.queue) == 0 {
+		return nil, nil
+	}
+
+	if c.depth <= 0 {
+		evs := c.queue
+		c.queue = nil
+		return evs, nil
+	}
+
+	// 缓存的链头已能确认最早的事件时不再查询，追块时避免每个事件都查询一次链头
+	if !c.confirmed(c.queue[0]) {
+		head, err := c.source.GetCurrentBlockHeight()
+		if err != nil {
+			return nil, err
+		}
+		c.head = int64(head)
+	}
+
+	var n int
+	for n < len(c.queue) && c.confirmed(c.queue[n]) {
+		n++
+	}
+
+	evs := c.queue[:n:n]
+	c.queue = c.queue[n:]
+
+	return evs, nil
+}
+
+// confirmed 按缓存的链头判断事件是否已达到确认深度
+func (c *confirmer) confirmed(ev *chain.ContractEvent) bool {
+	return ev.BlockHeight+c.depth <= c.head
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"chain-proxy/chain"
+	"chainmaker.org/chainmaker/pb-go/v2/common"
+	"context"
+	"errors"
+	"testing"
+)
+
+// headSource 返回固定链头高度的事件源，记录查询链头的次数
+type headSource struct {
+	head    uint64
+	err     error
+	queries int
+}
+
+func (s *headSource) SubscribeContractEvent(ctx context.Context, start, end int64, contract, topic string) (<-chan interface{}, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (s *headSource) GetCurrentBlockHeight() (uint64, error) {
+	s.queries++
+	return s.head, s.err
+}
+
+func (s *headSource) GetTxByTxId(txId string) (*common.TransactionInfo, error) {
+	return nil, nil
+}
+
+func TestConfirmerRelease(t *testing.T) {
+	tests := []struct {
+		name    string
+		depth   int64
+		head    uint64
+		heights []int64
+		want    []int64 // 释放的事件高度
+		pending int
+		queries int
+	}{
+		{
+			name:    "no depth",
+			depth:   0,
+			head:    0,
+			heights: []int64{10, 11},
+			want:    []int64{10, 11},
+		},
+		{
+			name:    "all confirmed",
+			depth:   2,
+			head:    20,
+			heights: []int64{10, 11, 18},
+			want:    []int64{10, 11, 18},
+			queries: 1,
+		},
+		{
+			name:    "partly confirmed",
+			depth:   2,
+			head:    12,
+			heights: []int64{10, 10, 11},
+			want:    []int64{10, 10},
+			pending: 1,
+			queries: 1,
+		},
+		{
+			name:    "none confirmed",
+			depth:   5,
+			head:    12,
+			heights: []int64{10},
+			pending: 1,
+			queries: 1,
+		},
+		{
+			name:  "empty",
+			depth: 2,
+			head:  12,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := &headSource{head: tt.head}
+			c := newConfirmer(src, tt.depth)
+			for _, h := range tt.heights {
+				c.push(&chain.ContractEvent{BlockHeight: h})
+			}
+
+			evs, err := c.release()
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if len(evs) != len(tt.want) {
+				t.Fatalf("released %d events, want %d", len(evs), len(tt.want))
+			}
+			for i, ev := range evs {
+				if ev.BlockHeight != tt.want[i] {
+					t.Fatalf("evs[%d] height = %d, want %d", i, ev.BlockHeight, tt.want[i])
+				}
+			}
+			if c.pending() != tt.pending {
+				t.Fatalf("pending = %d, want %d", c.pending(), tt.pending)
+			}
+			if src.queries != tt.queries {
+				t.Fatalf("queried head %d times, want %d", src.queries, tt.queries)
+			}
+		})
+	}
+}
+
+func TestConfirmerCachedHead(t *testing.T) {
+	src := &headSource{head: 20}
+	c := newConfirmer(src, 2)
+
+	c.push(&chain.ContractEvent{BlockHeight: 10})
+	_, err := c.release()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 缓存的链头已能确认的事件不再查询链头
+	c.push(&chain.ContractEvent{BlockHeight: 15})
+	evs, err := c.release()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evs) != 1 || src.queries != 1 {
+		t.Fatalf("released %d events with %d head queries, want 1 and 1", len(evs), src.queries)
+	}
+
+	// 超过缓存链头的事件重新查询
+	c.push(&chain.ContractEvent{BlockHeight: 19})
+	evs, err = c.release()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evs) != 0 || src.queries != 2 {
+		t.Fatalf("released %d events with %d head queries, want 0 and 2", len(evs), src.queries)
+	}
+	if h, ok := c.oldest(); !ok || h != 19 {
+		t.Fatalf("oldest = %d, %v, want 19, true", h, ok)
+	}
+}
+
+func TestConfirmerHeadError(t *testing.T) {
+	src := &headSource{err: errors.New("node unavailable")}
+	c := newConfirmer(src, 2)
+	c.push(&chain.ContractEvent{BlockHeight: 10})
+
+	evs, err := c.release()
+	if err == nil {
+		t.Fatal("err = nil, want error")
+	}
+	if len(evs) != 0 || c.pending() != 1 {
+		t.Fatalf("released %d events, pending %d, want 0 and 1", len(evs), c.pending())
+	}
+}
@@ -0,0 +1,165 @@
+package service
+
+// 拉取消费：按 sync_event_log 的 id 升序分页读取同步记录，游标为 id 的 base64 编码，对调用方不透明
+// 消费方处理完一批记录后提交游标，服务端按消费方记录已提交的位置
+import (
+	"chain-proxy/db"
+	"chain-proxy/db/model"
+	"encoding/base64"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultPullLimit = 100
+	maxPullLimit     = 1000
+)
+
+// EventSettleDelay 按 id 顺序读取时只返回写入超过该时间的记录：id 在插入时分配，多个监听任务并发写入时
+// 提交顺序可能与 id 顺序不一致，留出时间让较小 id 的事务先提交，避免游标越过尚未可见的记录
+const EventSettleDelay = 5 * time.Second
+
+// PullRequest 拉取请求，Cursor 为空时从消费方已提交的游标开始
+type PullRequest struct {
+	Consumer string
+	Cursor   string
+	Topic    string
+	UserId   string
+	Status   int // < 0 时不按状态过滤
+	Limit    int
+}
+
+// PullResult 拉取结果，NextCursor 为本批最后一条记录之后的游标，没有新记录时与请求的游标相同
+type PullResult struct {
+	Events     []*model.SyncEventLog `json:"events"`
+	NextCursor string                `json:"nextCursor"`
+}
+
+// PullEvents 按游标拉取同步记录
+func PullEvents(req *PullRequest) (*PullResult, error) {
+	after, err := pullStart(req)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultPullLimit
+	}
+	if limit > maxPullLimit {
+		limit = maxPullLimit
+	}
+
+	tx := eventsAfter(after, req.Topic, req.UserId)
+	if req.Status >= 0 {
+		tx = tx.Where(model.SyncStatusCol+" = ?", req.Status)
+	}
+
+	var srs []*model.SyncEventLog
+	err = tx.Order("id").
+		Limit(limit).
+		Find(&srs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	next := after
+	if len(srs) != 0 {
+		next = srs[len(srs)-1].ID
+	}
+
+	return &PullResult{
+		Events:     srs,
+		NextCursor: encodeCursor(next),
+	}, nil
+}
+
+// CommitCursor 提交消费方的游标，游标只增不减
+func CommitCursor(consumer, cursor string) error {
+	if consumer == "" {
+		return errors.New("consumer is required")
+	}
+
+	lastId, err := decodeCursor(cursor)
+	if err != nil {
+		return err
+	}
+
+	cc := &model.ConsumerCursor{
+		ConsumerId: consumer,
+		LastId:     lastId,
+	}
+
+	return db.GetGormDb().
+		Table(model.TableConsumerCursor).
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "consumer_id"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"last_id":    gorm.Expr("GREATEST(last_id, VALUES(last_id))"),
+				"updated_at": gorm.Expr("VALUES(updated_at)"),
+			}),
+		}).
+		Create(cc).Error
+}
+
+// pullStart 返回本次拉取的起始 id（不含），请求未携带游标时使用消费方已提交的游标
+func pullStart(req *PullRequest) (int, error) {
+	if req.Cursor != "" {
+		return decodeCursor(req.Cursor)
+	}
+	if req.Consumer == "" {
+		return 0, nil
+	}
+
+	var cc = new(model.ConsumerCursor)
+	err := db.GetGormDb().
+		Table(model.TableConsumerCursor).
+		Select("*").
+		Where("consumer_id = ?", req.Consumer).
+		Scan(cc).Error
+	if err != nil {
+		return 0, err
+	}
+
+	return cc.LastId, nil
+}
+
+// eventsAfter 构造查询 id 大于 after 且已过 EventSettleDelay 的同步记录的条件，topic、userId 为空时不过滤
+func eventsAfter(after int, topic, userId string) *gorm.DB {
+	tx := db.GetGormDb().
+		Table(model.TableSyncEventLog).
+		Where("id > ? and created_at <= ?", after, time.Now().Add(-EventSettleDelay))
+	if topic != "" {
+		tx = tx.Where("topic = ?", topic)
+	}
+	if userId != "" {
+		tx = tx.Where("user_id = ?", userId)
+	}
+
+	return tx
+}
+
+func encodeCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(id)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, errors.New("invalid cursor")
+	}
+
+	id, err := strconv.Atoi(string(b))
+	if err != nil || id < 0 {
+		return 0, errors.New("invalid cursor")
+	}
+
+	return id, nil
+}
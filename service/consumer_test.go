@@ -0,0 +1,46 @@
+package service
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestCursor(t *testing.T) {
+	for _, id := range []int{0, 1, 42, 1 << 30} {
+		got, err := decodeCursor(encodeCursor(id))
+		if err != nil {
+			t.Fatalf("decode cursor of %d: %v", id, err)
+		}
+		if got != id {
+			t.Fatalf("decodeCursor(encodeCursor(%d)) = %d", id, got)
+		}
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	tests := []struct {
+		name    string
+		cursor  string
+		want    int
+		wantErr bool
+	}{
+		{name: "empty", cursor: "", want: 0},
+		{name: "valid", cursor: base64.RawURLEncoding.EncodeToString([]byte("128")), want: 128},
+		{name: "not base64", cursor: "***", wantErr: true},
+		{name: "padded base64", cursor: base64.URLEncoding.EncodeToString([]byte("1")), wantErr: true},
+		{name: "not a number", cursor: base64.RawURLEncoding.EncodeToString([]byte("abc")), wantErr: true},
+		{name: "negative", cursor: base64.RawURLEncoding.EncodeToString([]byte("-1")), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeCursor(tt.cursor)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Fatalf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
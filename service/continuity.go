@@ -0,0 +1,218 @@
+package service
+
+// 用户余额连续性校验：上一次同步的余额 + 本次改变值 应等于本次事件的余额
+// 不连续说明中间有事件遗漏，记录标记为异常，并暂停推送该用户的事件直到人工释放
+// 各订阅独立监听、各自推进进度，同一用户不同 topic 的事件到达顺序与区块顺序不一致（如重启后追块），
+// 因此只校验所有订阅的监听进度都已越过的记录，并按区块顺序逐条校验，更低高度的事件不会在校验之后才到达
+import (
+	"chain-proxy/config"
+	"chain-proxy/db"
+	"chain-proxy/db/model"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"sync"
+)
+
+// 每次查询待校验记录的数量
+const validateBatchSize = 500
+
+// 同一进程中的监听任务依次校验，多个实例之间由记录上的条件更新与用户行锁保证每条记录只校验一次
+var validateMu sync.Mutex
+
+// validateSettled 按 (block_height, event_index, sub_index, id) 顺序校验应用链上已落定的记录，
+// 校验后仍为待发送的记录分发推送
+func validateSettled(cc *config.ChainClient) error {
+	validateMu.Lock()
+	defer validateMu.Unlock()
+
+	settled, err := settledHeight(cc)
+	if err != nil {
+		return err
+	}
+
+	for {
+		var srs []*model.SyncEventLog
+		err = db.GetGormDb().
+			Table(model.TableSyncEventLog).
+			Where("chain_id = ? and "+model.CheckedCol+" = ? and block_height <= ?", cc.ChainId, false, settled).
+			Order("block_height, event_index, sub_index, id").
+			Limit(validateBatchSize).
+			Find(&srs).Error
+		if err != nil {
+			return err
+		}
+
+		for _, sr := range srs {
+			err = validateRecord(sr)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(srs) < validateBatchSize {
+			return nil
+		}
+	}
+}
+
+// validateRecord 在事务中标记记录已校验并校验余额连续性，已被其他任务校验的记录跳过
+func validateRecord(sr *model.SyncEventLog) error {
+	var (
+		checked bool
+		anomaly string
+	)
+	err := db.GetGormDb().Transaction(func(tx *gorm.DB) error {
+		res := tx.Table(model.TableSyncEventLog).
+			Where("id = ? and "+model.CheckedCol+" = ?", sr.ID, false).
+			Update(model.CheckedCol, true)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		checked = true
+
+		var err error
+		anomaly, err = checkContinuity(tx, sr)
+		return err
+	})
+	if err != nil || !checked {
+		return err
+	}
+
+	if anomaly != "" {
+		raiseAlert("%s, pushes of this user are held", anomaly)
+		return nil
+	}
+
+	if sr.SyncStatus == int(StatusPending) {
+		dispatchPush(sr, pushPolicyOf(sr.EventType))
+	}
+
+	return nil
+}
+
+// checkContinuity 在事务中校验记录的余额连续性，并推进用户的已同步余额
+// 返回非空字符串表示余额不连续，记录已被标记为异常
+func checkContinuity(tx *gorm.DB, sr *model.SyncEventLog) (string, error) {
+	if sr.UserId == "" || sr.SyncStatus != int(StatusPending) {
+		return "", nil
+	}
+
+	var uar = new(model.UserAuth)
+	err := tx.Table(model.TableUserAuth).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ?", sr.UserId).
+		Take(uar).Error
+	if err != nil {
+		return "", err
+	}
+
+	prev, syncedHeight := uar.SyncedBalance, uar.SyncedHeight
+	if syncedHeight == 0 {
+		prev, syncedHeight, err = lastSynced(tx, uar, sr)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// 早于已同步高度的记录（如回填补录）不参与校验，也不回退已同步余额
+	if syncedHeight > sr.BlockHeight {
+		return "", nil
+	}
+
+	// 授权快照所在区块的变动已包含在授权时的钱包余额中，不参与校验
+	if sr.BlockHeight <= syncedHeight && sr.BlockHeight == uar.BlockHeight {
+		return "", nil
+	}
+
+	var anomaly string
+	if prev+sr.ChangeValue != sr.BalanceAfter {
+		anomaly = fmt.Sprintf("user %s balance discontinuity at height %d tx %s: last synced %d + change %d != balance %d",
+			sr.UserId, sr.BlockHeight, sr.TxId, prev, sr.ChangeValue, sr.BalanceAfter)
+
+		sr.SyncStatus = int(StatusAnomaly)
+		sr.ErrorMessage = anomaly
+		err = tx.Table(model.TableSyncEventLog).
+			Where("id = ?", sr.ID).
+			Updates(map[string]interface{}{
+				model.SyncStatusCol:   StatusAnomaly,
+				model.ErrorMessageCol: anomaly,
+			}).Error
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// 以链上余额为准推进，后续事件基于最新余额校验
+	updates := map[string]interface{}{
+		"synced_balance": sr.BalanceAfter,
+		"synced_height":  sr.BlockHeight,
+	}
+	if anomaly != "" {
+		updates["held"] = true
+	}
+
+	err = tx.Table(model.TableUserAuth).
+		Where("id = ?", uar.ID).
+		Updates(updates).Error
+	if err != nil {
+		return "", err
+	}
+
+	return anomaly, nil
+}
+
+// lastSynced 返回没有 synced_height 的用户（引入连续性校验之前已授权）的已同步余额与高度
+// 以该用户最近一条已校验的同步记录（不含本条）为准，没有时以授权时的钱包状态为准
+func lastSynced(tx *gorm.DB, uar *model.UserAuth, sr *model.SyncEventLog) (int64, int64, error) {
+	var last = new(model.SyncEventLog)
+	err := tx.Table(model.TableSyncEventLog).
+		Where("user_id = ? and id <> ? and "+model.SyncStatusCol+" <> ? and "+model.CheckedCol+" = ?", uar.UserId, sr.ID, StatusIgnored, true).
+		Order("block_height desc, event_index desc, sub_index desc").
+		Limit(1).
+		Scan(last).Error
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if last.ID == 0 {
+		return uar.Balance, uar.BlockHeight, nil
+	}
+
+	return last.BalanceAfter, last.BlockHeight, nil
+}
+
+// ReleaseUser 人工确认后释放被暂停推送的用户：异常记录恢复为待发送，并按顺序推送该用户待发送的记录
+func ReleaseUser(userId string) error {
+	err := db.GetGormDb().Transaction(func(tx *gorm.DB) error {
+		err := tx.Table(model.TableSyncEventLog).
+			Where("user_id = ? and sync_status = ?", userId, StatusAnomaly).
+			Update(model.SyncStatusCol, StatusPending).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Table(model.TableUserAuth).
+			Where("user_id = ?", userId).
+			Update("held", false).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	var srs []*model.SyncEventLog
+	err = db.GetGormDb().
+		Table(model.TableSyncEventLog).
+		Where("user_id = ? and sync_status = ? and "+model.CheckedCol+" = ?", userId, StatusPending, true).
+		Order("block_height, event_index, sub_index").
+		Find(&srs).Error
+	if err != nil {
+		return err
+	}
+
+	for _, sr := range srs {
+		dispatchPush(sr, pushPolicyOf(sr.EventType))
+	}
+
+	return nil
+}
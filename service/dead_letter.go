@@ -0,0 +1,210 @@
+package service
+
+// 死信事件：解析或校验失败的事件保存原始 json 与失败原因，修复后可重新投递
+import (
+	"chain-proxy/chain"
+	"chain-proxy/db"
+	"chain-proxy/db/model"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+const (
+	DeadLetterPending  = 0 // 待处理
+	DeadLetterRedriven = 1 // 已重投
+)
+
+// invalidEventError 事件解析或校验失败，重试无法恢复，需要进入死信
+type invalidEventError struct {
+	err error
+}
+
+func (e *invalidEventError) Error() string {
+	return e.err.Error()
+}
+
+func (e *invalidEventError) Unwrap() error {
+	return e.err
+}
+
+// isInvalidEvent 错误是否为事件解析或校验失败
+func isInvalidEvent(err error) bool {
+	var ie *invalidEventError
+	return errors.As(err, &ie)
+}
+
+// deadLetter 保存死信事件并推进监听进度，ev 为空时（sdk 事件无法转换）以订阅信息记录
+func (l *EventListener) deadLetter(raw interface{}, ev *chain.ContractEvent, reason error) error {
+	fmt.Printf("chain %s contract %s topic %s event dead lettered: %v\n", l.chain.ChainId, l.sub.ContractName, l.sub.Topic, reason)
+
+	dl := &model.DeadLetterEvent{
+		ChainId:      l.chain.ChainId,
+		ContractName: l.sub.ContractName,
+		Topic:        l.sub.Topic,
+		ErrorReason:  reason.Error(),
+		Status:       DeadLetterPending,
+	}
+
+	if ev != nil {
+		raw = ev
+		dl.Topic = ev.Topic
+		dl.BlockHeight = ev.BlockHeight
+		dl.TxId = ev.TxId
+		dl.EventIndex = ev.EventIndex
+	}
+
+	rawBytes, err := json.Marshal(raw)
+	if err != nil {
+		rawBytes = []byte(fmt.Sprintf("%q", fmt.Sprintf("%#v", raw)))
+	}
+	dl.RawEvent = string(rawBytes)
+
+	return db.GetGormDb().Transaction(func(tx *gorm.DB) error {
+		err := saveDeadLetter(tx, dl)
+		if err != nil {
+			return err
+		}
+
+		if ev == nil {
+			return nil
+		}
+
+		return l.advanceCheckpoint(tx, ev)
+	})
+}
+
+// saveDeadLetter 写入死信事件，同一事件已存在待处理的死信时只更新失败原因
+func saveDeadLetter(tx *gorm.DB, dl *model.DeadLetterEvent) error {
+	if dl.TxId != "" {
+		var id int
+		err := tx.Table(model.TableDeadLetterEvent).
+			Select("id").
+			Where("chain_id = ? and tx_id = ? and event_index = ? and topic = ? and status = ?",
+				dl.ChainId, dl.TxId, dl.EventIndex, dl.Topic, DeadLetterPending).
+			Scan(&id).Error
+		if err != nil {
+			return err
+		}
+
+		if id != 0 {
+			return tx.Table(model.TableDeadLetterEvent).
+				Where("id = ?", id).
+				Updates(map[string]interface{}{
+					"raw_event":    dl.RawEvent,
+					"error_reason": dl.ErrorReason,
+				}).Error
+		}
+	}
+
+	return tx.Table(model.TableDeadLetterEvent).Create(dl).Error
+}
+
+// ListDeadLetters 分页查询死信事件，status < 0 时不过滤状态
+func ListDeadLetters(status, page, size int) ([]*model.DeadLetterEvent, int64, error) {
+	if page <= 0 {
+		page = 1
+	}
+	if size <= 0 || size > 100 {
+		size = 20
+	}
+
+	tx := db.GetGormDb().Table(model.TableDeadLetterEvent)
+	if status >= 0 {
+		tx = tx.Where("status = ?", status)
+	}
+
+	var total int64
+	err := tx.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var dls []*model.DeadLetterEvent
+	err = tx.Order("id desc").
+		Offset((page - 1) * size).
+		Limit(size).
+		Find(&dls).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return dls, total, nil
+}
+
+// RedriveDeadLetter 将死信事件重新投递到正常的事件处理流程，成功后标记为已重投
+// 重新投递不推进监听进度，同步记录由唯一键去重
+func RedriveDeadLetter(id int) (*BackfillResult, error) {
+	var dl = new(model.DeadLetterEvent)
+	err := db.GetGormDb().
+		Table(model.TableDeadLetterEvent).
+		Where("id = ?", id).
+		Take(dl).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if dl.Status == DeadLetterRedriven {
+		return nil, fmt.Errorf("dead letter %d has been redriven", id)
+	}
+
+	ev := new(chain.ContractEvent)
+	err = json.Unmarshal([]byte(dl.RawEvent), ev)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal raw event into ContractEvent struct")
+	}
+
+	cc, err := findChain(dl.ChainId)
+	if err != nil {
+		return nil, err
+	}
+
+	src, ok := chain.GetEventSource(cc.ChainId)
+	if !ok {
+		return nil, fmt.Errorf("event source of chain %s not registered", cc.ChainId)
+	}
+
+	l, err := NewEventListener(cc, src, findSubscription(cc, dl.ContractName, dl.Topic))
+	if err != nil {
+		return nil, err
+	}
+	l.backfill = true
+
+	outcomes, handleErr := l.handleContractEvent(ev)
+
+	updates := map[string]interface{}{
+		"redrive_count": gorm.Expr("redrive_count + 1"),
+	}
+	if handleErr != nil {
+		updates["error_reason"] = handleErr.Error()
+	} else {
+		updates["status"] = DeadLetterRedriven
+	}
+
+	err = db.GetGormDb().
+		Table(model.TableDeadLetterEvent).
+		Where("id = ?", id).
+		Updates(updates).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if handleErr != nil {
+		return nil, handleErr
+	}
+
+	res := new(BackfillResult)
+	for _, outcome := range outcomes {
+		switch outcome {
+		case outcomeInserted:
+			res.Inserted++
+		case outcomeDuplicated:
+			res.Duplicated++
+		default:
+			res.Skipped++
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,70 @@
+package service
+
+// 同步记录的送达：根据配置选择 sink，将同步记录转换为推送信封后送达接收方
+import (
+	"chain-proxy/config"
+	"chain-proxy/db"
+	"chain-proxy/db/model"
+	"chain-proxy/sink"
+	"encoding/json"
+	"github.com/pkg/errors"
+)
+
+var deliverSink sink.Sink
+
+// InitSink 根据配置初始化同步记录的送达方式
+func InitSink() error {
+	s, err := sink.New(config.GetConfigInstance().Sink)
+	if err != nil {
+		return err
+	}
+
+	deliverSink = s
+	return nil
+}
+
+// userDcids 一条语句查询用户的数币 dcid
+func userDcids(userIds []string) (map[string]string, error) {
+	var uars []*model.UserAuth
+	err := db.GetGormDb().
+		Table(model.TableUserAuth).
+		Select("user_id, dcid").
+		Where("user_id in ?", userIds).
+		Find(&uars).Error
+	if err != nil {
+		return nil, err
+	}
+
+	dcids := make(map[string]string, len(uars))
+	for _, uar := range uars {
+		dcids[uar.UserId] = uar.Dcid
+	}
+
+	return dcids, nil
+}
+
+// newSinkEvent 根据同步记录构造推送信封，用户以数币 dcid 标识
+func newSinkEvent(sr *model.SyncEventLog, dcid string) (*sink.Event, error) {
+	ev := &sink.Event{
+		Id:           sr.ID,
+		ChainId:      sr.ChainId,
+		UserId:       sr.UserId,
+		Dcid:         dcid,
+		EventType:    sr.EventType,
+		Topic:        sr.Topic,
+		ContractName: sr.ContractName,
+		TxId:         sr.TxId,
+		BlockHeight:  sr.BlockHeight,
+		Balance:      sr.BalanceAfter,
+		ChangeValue:  sr.ChangeValue,
+	}
+
+	if sr.EventPayload != "" {
+		if !json.Valid([]byte(sr.EventPayload)) {
+			return nil, errors.Errorf("invalid event payload of sync event %d", sr.ID)
+		}
+		ev.Payload = json.RawMessage(sr.EventPayload)
+	}
+
+	return ev, nil
+}
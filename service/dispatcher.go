@@ -0,0 +1,176 @@
+package service
+
+// 推送分发：按 UserId 哈希到固定数量的通道，每个通道单协程顺序推送
+// 同一用户的记录总在同一通道中，保持推送顺序；不同用户的记录在不同通道中并发推送，慢推送只阻塞所在通道
+import (
+	"chain-proxy/config"
+	"chain-proxy/db/model"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+)
+
+const (
+	defaultDispatchLanes     = 8
+	defaultDispatchQueueSize = 1024
+)
+
+type pushTask struct {
+	sr     *model.SyncEventLog
+	policy PushPolicy
+}
+
+type dispatcher struct {
+	ctx   context.Context
+	lanes []chan *pushTask
+
+	mu sync.Mutex
+	// 已在通道中排队或正在推送的记录 id，重试调度不会重复分发
+	queued map[int]struct{}
+}
+
+// LaneDepth 单个通道的排队情况
+type LaneDepth struct {
+	Lane     int `json:"lane"`
+	Depth    int `json:"depth"`
+	Capacity int `json:"capacity"`
+}
+
+var (
+	dispatchMu sync.RWMutex
+	// 运行中的分发器，未运行时（如回填子命令）直接在调用方推送
+	activeDispatcher *dispatcher
+)
+
+// RunDispatcher 启动推送分发任务，退出时通道中未推送的记录保持待发送，由重试调度重新推送
+func RunDispatcher(ctx context.Context) error {
+	lanes, size := defaultDispatchLanes, defaultDispatchQueueSize
+	if dc := config.GetConfigInstance().Dispatch; dc != nil {
+		if dc.Lanes > 0 {
+			lanes = dc.Lanes
+		}
+		if dc.QueueSize > 0 {
+			size = dc.QueueSize
+		}
+	}
+
+	d := &dispatcher{
+		ctx:    ctx,
+		lanes:  make([]chan *pushTask, lanes),
+		queued: make(map[int]struct{}),
+	}
+	for i := range d.lanes {
+		d.lanes[i] = make(chan *pushTask, size)
+	}
+
+	// sink 支持批量且配置了批量上限时按批推送，否则每批一条
+	bc, bs := batchConfig()
+	if bc == nil {
+		bc = &config.Batch{MaxCount: 1}
+	}
+
+	var wg sync.WaitGroup
+	for i := range d.lanes {
+		wg.Add(1)
+		go func(ch chan *pushTask) {
+			defer wg.Done()
+			d.runBatchLane(ch, bc)
+		}(d.lanes[i])
+	}
+
+	dispatchMu.Lock()
+	activeDispatcher = d
+	dispatchMu.Unlock()
+	fmt.Printf("push dispatcher started with %d lanes, batch %v\n", lanes, bs != nil)
+
+	<-ctx.Done()
+
+	dispatchMu.Lock()
+	activeDispatcher = nil
+	dispatchMu.Unlock()
+
+	wg.Wait()
+	fmt.Printf("push dispatcher recv ctx cancel signal, will close\n")
+
+	return ctx.Err()
+}
+
+// laneOf 返回用户所在的通道
+func (d *dispatcher) laneOf(userId string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(userId))
+	return int(h.Sum32() % uint32(len(d.lanes)))
+}
+
+// dispatchPush 将记录分发到用户所在的通道，通道已满时等待；分发器未运行时直接推送
+// 记录已在通道中排队时不重复分发，返回是否分发
+func dispatchPush(sr *model.SyncEventLog, policy PushPolicy) bool {
+	dispatchMu.RLock()
+	d := activeDispatcher
+	dispatchMu.RUnlock()
+
+	if d == nil {
+		pushEvent(sr, policy)
+		return true
+	}
+
+	if !d.acquire(sr.ID) {
+		return false
+	}
+
+	t := &pushTask{sr: sr, policy: policy}
+	select {
+	case d.lanes[d.laneOf(sr.UserId)] <- t:
+		return true
+	case <-d.ctx.Done():
+		d.release(t)
+		fmt.Printf("push dispatcher closed, event id %d stays pending\n", sr.ID)
+		return false
+	}
+}
+
+// acquire 标记记录已排队，已排队时返回 false
+func (d *dispatcher) acquire(id int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.queued[id]; ok {
+		return false
+	}
+	d.queued[id] = struct{}{}
+
+	return true
+}
+
+// release 记录推送结束后取消排队标记
+func (d *dispatcher) release(tasks ...*pushTask) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, t := range tasks {
+		delete(d.queued, t.sr.ID)
+	}
+}
+
+// LaneDepths 返回各通道当前的排队数量，分发器未运行时为空
+func LaneDepths() []*LaneDepth {
+	dispatchMu.RLock()
+	d := activeDispatcher
+	dispatchMu.RUnlock()
+
+	if d == nil {
+		return nil
+	}
+
+	depths := make([]*LaneDepth, len(d.lanes))
+	for i, ch := range d.lanes {
+		depths[i] = &LaneDepth{
+			Lane:     i,
+			Depth:    len(ch),
+			Capacity: cap(ch),
+		}
+	}
+
+	return depths
+}
@@ -0,0 +1,76 @@
+package service
+
+// 积分兑换（核销）事件：与收集事件分开处理，接收方对兑换与收益采用不同的处理逻辑
+import (
+	"chain-proxy/chain"
+	"chain-proxy/db/model"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+const (
+	// HandlerExchange 积分兑换事件处理器
+	HandlerExchange = "exchange"
+)
+
+// 兑换事件推送失败的最大战略重试次数，兑换影响接收方的核销，多给几次机会
+const exchangeMaxPushAttempts = 5
+
+// exchangeHandler 积分兑换事件处理器
+var exchangeHandler = &EventHandler{
+	Name:    HandlerExchange,
+	Decode:  decodeExchangeEvent,
+	Persist: persistExchangeEvent,
+	Push: PushPolicy{
+		MaxAttempts: exchangeMaxPushAttempts,
+	},
+}
+
+// decodeExchangeEvent 解析积分兑换事件，EventData 中的每一项对应一个地址的兑换
+func decodeExchangeEvent(ev *chain.ContractEvent) ([]*EventEntry, error) {
+	if len(ev.EventData) == 0 {
+		return nil, errors.New("event data is empty")
+	}
+
+	entries := make([]*EventEntry, len(ev.EventData))
+	for i, data := range ev.EventData {
+		evData := new(ExchangeEventInfo)
+		err := json.Unmarshal([]byte(data), evData)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to unmarshal event data[%d] into ExchangeEventInfo struct", i)
+		}
+
+		entries[i] = &EventEntry{
+			Address:     evData.Address,
+			Height:      evData.Height,
+			Balance:     evData.Balance,
+			ChangeValue: -evData.ExchangeValue, // 兑换减少余额
+			Payload: &ExchangePayload{
+				Addr:          evData.Address,
+				Balance:       evData.Balance,
+				ExchangeValue: evData.ExchangeValue,
+				OrderId:       evData.OrderId,
+				ExchangeMap:   evData.ExchangeMap,
+			},
+		}
+	}
+
+	return entries, nil
+}
+
+// persistExchangeEvent 写入兑换同步记录
+// 兑换值非正数或兑换后余额为负数的记录属于异常数据，以已忽略状态落库并记录原因，不会推送给接收方
+func persistExchangeEvent(tx *gorm.DB, sr *model.SyncEventLog) (bool, error) {
+	switch {
+	case sr.ChangeValue >= 0:
+		sr.SyncStatus = int(StatusIgnored)
+		sr.ErrorMessage = fmt.Sprintf("invalid exchange value %d", -sr.ChangeValue)
+	case sr.BalanceAfter < 0:
+		sr.SyncStatus = int(StatusIgnored)
+		sr.ErrorMessage = fmt.Sprintf("invalid balance %d after exchange", sr.BalanceAfter)
+	}
+
+	return persistSyncEventLog(tx, sr)
+}
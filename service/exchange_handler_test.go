@@ -0,0 +1,76 @@
+package service
+
+import (
+	"chain-proxy/chain"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeExchangeEvent(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []string
+		want    []*EventEntry
+		wantErr bool
+	}{
+		{
+			name: "single address",
+			data: []string{`{"address":"a","height":12,"balance":25,"exchangeValue":5,"orderId":"o1","txId":"tx1","exchangeMap":{"h1":5}}`},
+			want: []*EventEntry{
+				{
+					Address:     "a",
+					Height:      12,
+					Balance:     25,
+					ChangeValue: -5,
+					Payload: &ExchangePayload{
+						Addr:          "a",
+						Balance:       25,
+						ExchangeValue: 5,
+						OrderId:       "o1",
+						ExchangeMap:   map[string]int{"h1": 5},
+					},
+				},
+			},
+		},
+		{
+			name: "batch",
+			data: []string{
+				`{"address":"a","height":12,"balance":25,"exchangeValue":5,"orderId":"o1"}`,
+				`{"address":"b","height":12,"balance":0,"exchangeValue":10,"orderId":"o2"}`,
+			},
+			want: []*EventEntry{
+				{Address: "a", Height: 12, Balance: 25, ChangeValue: -5,
+					Payload: &ExchangePayload{Addr: "a", Balance: 25, ExchangeValue: 5, OrderId: "o1"}},
+				{Address: "b", Height: 12, Balance: 0, ChangeValue: -10,
+					Payload: &ExchangePayload{Addr: "b", Balance: 0, ExchangeValue: 10, OrderId: "o2"}},
+			},
+		},
+		{
+			name:    "empty event data",
+			wantErr: true,
+		},
+		{
+			name:    "invalid json",
+			data:    []string{`[1, 2]`},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeExchangeEvent(&chain.ContractEvent{EventData: tt.data})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("err = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package service
+
+// 合约事件处理器注册表，每个 (合约, topic) 注册一个处理器
+import (
+	"chain-proxy/chain"
+	"chain-proxy/config"
+	"chain-proxy/db"
+	"chain-proxy/db/model"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"sync"
+)
+
+const (
+	// HandlerCollect 碳积分收集事件处理器
+	HandlerCollect = "collect"
+)
+
+// 推送失败的默认最大战略重试次数
+const defaultMaxPushAttempts = 3
+
+// EventEntry 事件数据项解析后的通用结构，EventData 中的每一项对应一个
+type EventEntry struct {
+	Address     string      // 用户钱包地址
+	Height      int64       // 变动发生的区块高度
+	Balance     int64       // 变动后余额
+	ChangeValue int64       // 改变值
+	Payload     interface{} // 推送给接收方的附加数据，序列化后存入 event_payload
+}
+
+// PushPolicy 同步记录的推送策略
+type PushPolicy struct {
+	// 推送失败的最大战略重试次数，<= 0 时使用 Retry 配置或默认值
+	MaxAttempts int
+}
+
+// EventHandler 合约事件处理器
+// Validate、Persist 为空时使用默认实现：按地址校验用户授权、按唯一键去重写入
+type EventHandler struct {
+	// 处理器名称，同时作为同步记录的 event_type
+	Name string
+	// 将 EventData 中的每一项解析为 EventEntry，返回的切片与 EventData 一一对应
+	Decode func(ev *chain.ContractEvent) ([]*EventEntry, error)
+	// 校验数据项是否需要同步，返回 nil 表示跳过该项
+	Validate func(entry *EventEntry) (*model.UserAuth, error)
+	// 在事务中写入同步记录，返回是否新写入
+	Persist func(tx *gorm.DB, sr *model.SyncEventLog) (bool, error)
+	// 推送策略
+	Push PushPolicy
+}
+
+type handlerKey struct {
+	contract string
+	topic    string
+}
+
+var (
+	handlerMu sync.RWMutex
+	// 按名称注册的处理器，订阅配置中的 handler 字段从这里选择
+	namedHandlers = map[string]*EventHandler{
+		HandlerCollect:  collectHandler,
+		HandlerSplit:    splitHandler,
+		HandlerExchange: exchangeHandler,
+	}
+	// 按 (合约, topic) 注册的处理器，监听任务据此分发事件
+	handlers = make(map[handlerKey]*EventHandler)
+)
+
+// RegisterHandler 为 (合约, topic) 注册事件处理器，同一个 key 不允许注册不同的处理器
+func RegisterHandler(contract, topic string, h *EventHandler) error {
+	if h == nil || h.Decode == nil {
+		return errors.New("event handler decoder is required")
+	}
+
+	handlerMu.Lock()
+	defer handlerMu.Unlock()
+
+	key := handlerKey{contract: contract, topic: topic}
+	if old, ok := handlers[key]; ok && old != h {
+		return fmt.Errorf("contract %s topic %s already registered with handler %s", contract, topic, old.Name)
+	}
+	handlers[key] = h
+
+	return nil
+}
+
+// LookupHandler 查找 (合约, topic) 对应的事件处理器
+func LookupHandler(contract, topic string) (*EventHandler, bool) {
+	handlerMu.RLock()
+	defer handlerMu.RUnlock()
+
+	h, ok := handlers[handlerKey{contract: contract, topic: topic}]
+	return h, ok
+}
+
+// registerSubscription 按订阅配置中的 handler 名称为其注册处理器
+func registerSubscription(sub *config.Subscription) error {
+	h, ok := namedHandler(sub.Handler)
+	if !ok {
+		return fmt.Errorf("unknown event handler %s for contract %s topic %s", sub.Handler, sub.ContractName, sub.Topic)
+	}
+
+	return RegisterHandler(sub.ContractName, sub.Topic, h)
+}
+
+// pushPolicyOf 返回同步记录所属处理器的推送策略，处理器不存在时使用默认策略
+func pushPolicyOf(eventType string) PushPolicy {
+	if h, ok := namedHandler(eventType); ok {
+		return h.Push
+	}
+
+	return PushPolicy{}
+}
+
+// namedHandler 按名称查找处理器
+func namedHandler(name string) (*EventHandler, bool) {
+	handlerMu.RLock()
+	defer handlerMu.RUnlock()
+
+	h, ok := namedHandlers[name]
+	return h, ok
+}
+
+// validate 校验数据项，自定义校验返回的错误视为事件无效
+func (h *EventHandler) validate(entry *EventEntry) (*model.UserAuth, error) {
+	if h.Validate != nil {
+		uar, err := h.Validate(entry)
+		if err != nil {
+			return nil, &invalidEventError{err: err}
+		}
+		return uar, nil
+	}
+
+	return validateAuthorized(entry)
+}
+
+func (h *EventHandler) persist(tx *gorm.DB, sr *model.SyncEventLog) (bool, error) {
+	if h.Persist != nil {
+		return h.Persist(tx, sr)
+	}
+
+	return persistSyncEventLog(tx, sr)
+}
+
+// buildRecord 根据数据项与授权用户构造同步记录，chain_id、合约名与序号由监听任务填写
+func (h *EventHandler) buildRecord(ev *chain.ContractEvent, entry *EventEntry, uar *model.UserAuth) (*model.SyncEventLog, error) {
+	sr := &model.SyncEventLog{
+		UserId:       uar.UserId,
+		BlockHeight:  entry.Height,
+		BalanceAfter: entry.Balance,
+		ChangeValue:  entry.ChangeValue,
+		Topic:        ev.Topic,
+		EventType:    h.Name,
+		TxId:         ev.TxId, // 唯一键的一部分，以链上事件为准，不使用事件数据中的 txId
+		SyncStatus:   int(StatusPending),
+		RetryCount:   0,
+	}
+
+	if entry.Payload != nil {
+		payload, err := json.Marshal(entry.Payload)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal event payload")
+		}
+		sr.EventPayload = string(payload)
+	}
+
+	return sr, nil
+}
+
+// maxAttempts 返回推送失败的最大战略重试次数
+func (p PushPolicy) maxAttempts() int {
+	if p.MaxAttempts > 0 {
+		return p.MaxAttempts
+	}
+	if rc := config.GetConfigInstance().Retry; rc != nil && rc.MaxAttempts > 0 {
+		return rc.MaxAttempts
+	}
+
+	return defaultMaxPushAttempts
+}
+
+// validateAuthorized 默认校验：用户已授权，且变动发生在授权之后
+func validateAuthorized(entry *EventEntry) (*model.UserAuth, error) {
+	// 查询是否已授权
+	var uar = new(model.UserAuth)
+	err := db.GetGormDb().
+		Table(model.TableUserAuth).
+		Select("*").
+		Where("addr = ?", entry.Address).
+		Scan(&uar).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if uar.ID == 0 {
+		// 说明未授权
+		fmt.Printf("user %s has not auth\n", entry.Address)
+		return nil, nil
+	}
+
+	if uar.BlockHeight > entry.Height {
+		// 该事件在余额授权同步之前发生，属于无效事件
+		fmt.Printf("this event info is invalid %+v\n", entry)
+		return nil, nil
+	}
+
+	return uar, nil
+}
+
+// persistSyncEventLog 默认写入：依赖唯一键去重，已存在时不写入
+func persistSyncEventLog(tx *gorm.DB, sr *model.SyncEventLog) (bool, error) {
+	res := tx.Table(model.TableSyncEventLog).
+		Clauses(clause.OnConflict{
+			DoNothing: true,
+		}).
+		Create(sr)
+	if res.Error != nil {
+		return false, res.Error
+	}
+
+	return res.RowsAffected > 0, nil
+}
+
+// recordUnknownEvent 记录没有注册处理器的事件，以已忽略状态落库，不做解析与推送
+func recordUnknownEvent(tx *gorm.DB, ev *chain.ContractEvent) (bool, error) {
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to marshal unknown event")
+	}
+
+	return persistSyncEventLog(tx, &model.SyncEventLog{
+		ChainId:      ev.ChainId,
+		BlockHeight:  ev.BlockHeight,
+		Topic:        ev.Topic,
+		TxId:         ev.TxId,
+		EventIndex:   ev.EventIndex,
+		ContractName: ev.ContractName,
+		EventPayload: string(payload),
+		SyncStatus:   int(StatusIgnored),
+		ErrorMessage: fmt.Sprintf("no handler registered for contract %s topic %s", ev.ContractName, ev.Topic),
+	})
+}
+
+// collectHandler 碳积分收集事件处理器
+var collectHandler = &EventHandler{
+	Name:   HandlerCollect,
+	Decode: decodeCollectEvent,
+}
+
+// decodeCollectEvent 解析碳积分收集事件，批量收集时 EventData 中的每一项对应一个地址的余额变动
+func decodeCollectEvent(ev *chain.ContractEvent) ([]*EventEntry, error) {
+	if len(ev.EventData) == 0 {
+		return nil, errors.New("event data is empty")
+	}
+
+	entries := make([]*EventEntry, len(ev.EventData))
+	for i, data := range ev.EventData {
+		evData := new(CollectEventInfo)
+		err := json.Unmarshal([]byte(data), evData)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to unmarshal event data[%d] into CollectEventInfo struct", i)
+		}
+
+		entries[i] = &EventEntry{
+			Address:     evData.Address,
+			Height:      evData.Height,
+			Balance:     evData.Balance,
+			ChangeValue: evData.ChangeValue,
+		}
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"chain-proxy/chain"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeCollectEvent(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []string
+		want    []*EventEntry
+		wantErr bool
+	}{
+		{
+			name: "single address",
+			data: []string{`{"address":"a","height":12,"balance":30,"changeValue":10,"txId":"tx1"}`},
+			want: []*EventEntry{
+				{Address: "a", Height: 12, Balance: 30, ChangeValue: 10},
+			},
+		},
+		{
+			name: "batch",
+			data: []string{
+				`{"address":"a","height":12,"balance":30,"changeValue":10}`,
+				`{"address":"b","height":12,"balance":15,"changeValue":15}`,
+			},
+			want: []*EventEntry{
+				{Address: "a", Height: 12, Balance: 30, ChangeValue: 10},
+				{Address: "b", Height: 12, Balance: 15, ChangeValue: 15},
+			},
+		},
+		{
+			name:    "empty event data",
+			wantErr: true,
+		},
+		{
+			name:    "invalid json in batch",
+			data:    []string{`{"address":"a"}`, `not json`},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeCollectEvent(&chain.ContractEvent{EventData: tt.data})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("err = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
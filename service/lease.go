@@ -0,0 +1,107 @@
+package service
+
+// 推送认领的租约：pushEvent 认领记录时写入实例与租约到期时间，需要接收方确认时送达后租约延长至确认超时，
+// 回收任务在启动时及定时将租约过期仍处于已发送的记录恢复为待发送，由重试调度重新推送
+import (
+	"chain-proxy/config"
+	"chain-proxy/db"
+	"chain-proxy/db/model"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"os"
+	"time"
+)
+
+const (
+	defaultLeaseDuration      = 10 * time.Minute
+	defaultLeaseSweepInterval = time.Minute
+)
+
+// instanceId 当前进程的实例标识，写入认领记录的 locked_by
+var instanceId = newInstanceId()
+
+func newInstanceId() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), uuid.New().String()[:8])
+}
+
+// SweepLeases 租约回收任务，启动时先回收一次，之后定时回收
+func SweepLeases(ctx context.Context) error {
+	interval := defaultLeaseSweepInterval
+	if lc := config.GetConfigInstance().Lease; lc != nil && lc.SweepInterval > 0 {
+		interval = time.Duration(lc.SweepInterval) * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := sweepExpiredLeases()
+		if err != nil {
+			fmt.Printf("sweep expired leases failed: %v\n", err)
+		} else if n > 0 {
+			fmt.Printf("sweep expired leases done, %d events back to pending\n", n)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			fmt.Printf("lease sweeper recv ctx cancel signal, will close\n")
+			return ctx.Err()
+		}
+	}
+}
+
+// sweepExpiredLeases 回收租约过期的已发送记录，返回回收的数量
+// 推送中途退出、尚未送达的记录恢复为待发送并立即到期重试，不计入战略失败次数；
+// 没有租约的已发送记录来自引入租约之前的版本，同样视为过期；
+// 已送达但超时未确认的记录计入一次战略失败，避免接收方始终不确认时无限重推
+func sweepExpiredLeases() (int64, error) {
+	now := time.Now()
+	res := db.GetGormDb().
+		Table(model.TableSyncEventLog).
+		Where(model.SyncStatusCol+" = ? and "+model.DeliveredAtCol+" is null", StatusSent).
+		Where("("+model.LeaseUntilCol+" < ? or "+model.LeaseUntilCol+" is null)", now).
+		Updates(map[string]interface{}{
+			model.SyncStatusCol:   StatusPending,
+			model.NextRetryAtCol:  now,
+			model.LockedByCol:     "",
+			model.LeaseUntilCol:   nil,
+			model.ErrorMessageCol: "lease expired before delivery",
+		})
+	if res.Error != nil {
+		return 0, res.Error
+	}
+	n := res.RowsAffected
+
+	var srs []*model.SyncEventLog
+	err := db.GetGormDb().
+		Table(model.TableSyncEventLog).
+		Where(model.SyncStatusCol+" = ? and "+model.DeliveredAtCol+" is not null", StatusSent).
+		Where(model.LeaseUntilCol+" < ?", now).
+		Find(&srs).Error
+	if err != nil {
+		return n, err
+	}
+
+	failures := make([]*pushFailure, len(srs))
+	for i, sr := range srs {
+		failures[i] = &pushFailure{sr: sr, reason: "acknowledgement timed out", policy: pushPolicyOf(sr.EventType)}
+	}
+	err = markPushFailed(failures)
+	if err != nil {
+		return n, err
+	}
+
+	return n + int64(len(srs)), nil
+}
+
+// leaseDuration 返回认领记录的租约时长
+func leaseDuration() time.Duration {
+	if lc := config.GetConfigInstance().Lease; lc != nil && lc.Duration > 0 {
+		return time.Duration(lc.Duration) * time.Second
+	}
+
+	return defaultLeaseDuration
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// ListenerStatus 监听任务的运行状态
+type ListenerStatus struct {
+	ChainId      string `json:"chainId"`
+	ContractName string `json:"contractName"`
+	Topic        string `json:"topic"`
+	Reconnects   int64  `json:"reconnects"` // 重新订阅的累计次数
+}
+
+var (
+	listenerMu sync.RWMutex
+	// 运行中的监听任务，回填与死信重投创建的监听任务不会运行 Run，不在其中
+	runningListeners = make(map[*EventListener]struct{})
+)
+
+func trackListener(l *EventListener) func() {
+	listenerMu.Lock()
+	runningListeners[l] = struct{}{}
+	listenerMu.Unlock()
+
+	return func() {
+		listenerMu.Lock()
+		delete(runningListeners, l)
+		listenerMu.Unlock()
+	}
+}
+
+// ListenerStatuses 返回所有运行中的监听任务状态
+func ListenerStatuses() []*ListenerStatus {
+	listenerMu.RLock()
+	defer listenerMu.RUnlock()
+
+	statuses := make([]*ListenerStatus, 0, len(runningListeners))
+	for l := range runningListeners {
+		statuses = append(statuses, &ListenerStatus{
+			ChainId:      l.chain.ChainId,
+			ContractName: l.sub.ContractName,
+			Topic:        l.sub.Topic,
+			Reconnects:   atomic.LoadInt64(&l.reconnects),
+		})
+	}
+
+	return statuses
+}
@@ -11,10 +11,13 @@ const (
 	StatusSuccess SyncStatus = 2 // 已成功
 	StatusFailed  SyncStatus = 3 // 失败
 	StatusIgnored SyncStatus = 4 // 已忽略
+	StatusAnomaly SyncStatus = 5 // 余额不连续，待人工确认
 )
 
 const (
-	CarbonIntegralChangeTopic = "cic_topic"
+	CarbonIntegralChangeTopic   = "cic_topic"
+	CarbonIntegralSplitTopic    = "cis_topic"
+	CarbonIntegralExchangeTopic = "cie_topic"
 )
 
 type AuthRequest struct {
@@ -80,15 +83,6 @@ type WalletResp struct {
 	WalletHistoryInfo []*WalletInfoDetail `json:"walletHistoryInfo"`
 }
 
-type Event struct {
-	BlockHeight  int64    `json:"block_height"`
-	ChainId      string   `json:"chain_id"`
-	Topic        string   `json:"topic"`
-	TxId         string   `json:"tx_id"`
-	ContractName string   `json:"contract_name"`
-	EventData    []string `json:"event_data" gorm:"type:longtext;serializer:json"`
-}
-
 type CollectEventInfo struct {
 	Address     string `json:"address"`     // 用户钱包地址
 	Height      int64  `json:"height"`      // 当前区块高度
@@ -97,6 +91,48 @@ type CollectEventInfo struct {
 	TxId        string `json:"txId"`        // 交易 id
 }
 
+// SplitEventInfo 积分拆分事件数据，拆分只在未拆分与已拆分积分之间移动，不改变余额
+type SplitEventInfo struct {
+	Address string `json:"address"` // 用户钱包地址
+	Height  int64  `json:"height"`  // 当前区块高度
+	Balance int64  `json:"balance"` // 当前余额
+	TxId    string `json:"txId"`    // 交易 id
+	// 本次从未拆分积分移动到已拆分积分的数量，key 为积分 hash
+	SplitMap map[string]int `json:"splitMap"`
+	// 拆分后的钱包
+	Wallet *Wallet `json:"wallet"`
+}
+
+// SplitPayload 积分拆分事件推送给接收方的数据，同时携带未拆分与已拆分积分，便于区分两类余额
+type SplitPayload struct {
+	Addr             string         `json:"addr"`
+	Balance          int64          `json:"balance"`
+	SplitMap         map[string]int `json:"splitMap"`
+	IntegralMap      map[string]int `json:"integralMap"`
+	SplitIntegralMap map[string]int `json:"splitIntegralMap"`
+}
+
+// ExchangeEventInfo 积分兑换（核销）事件数据
+type ExchangeEventInfo struct {
+	Address       string `json:"address"`       // 用户钱包地址
+	Height        int64  `json:"height"`        // 当前区块高度
+	Balance       int64  `json:"balance"`       // 兑换后余额
+	ExchangeValue int64  `json:"exchangeValue"` // 兑换消耗的积分，正数
+	OrderId       string `json:"orderId"`       // 兑换订单号
+	TxId          string `json:"txId"`          // 交易 id
+	// 本次兑换消耗的积分，key 为积分 hash
+	ExchangeMap map[string]int `json:"exchangeMap"`
+}
+
+// ExchangePayload 积分兑换事件推送给接收方的数据
+type ExchangePayload struct {
+	Addr          string         `json:"addr"`
+	Balance       int64          `json:"balance"`
+	ExchangeValue int64          `json:"exchangeValue"`
+	OrderId       string         `json:"orderId"`
+	ExchangeMap   map[string]int `json:"exchangeMap"`
+}
+
 type GetUserAddrResp struct {
 	Addr string `json:"addr"`
 }
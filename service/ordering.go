@@ -0,0 +1,83 @@
+package service
+
+// 用户内推送顺序：同一用户在同一条链上的记录按 (block_height, event_index, sub_index, id) 顺序推送
+// 更早的记录尚未完成（成功、失败或忽略）时，后续记录保持待发送，等更早的记录完成后由重试调度推送
+// 各订阅独立监听，进度落后的订阅可能还有更早的记录尚未落库，记录所在高度超过应用链所有订阅中最低的监听进度时同样保持待发送
+import (
+	"chain-proxy/db"
+	"chain-proxy/db/model"
+)
+
+// blockedByEarlier 返回 ids 中尚未落定或存在更早未完成记录的同步记录 id
+func blockedByEarlier(ids []int) (map[int]bool, error) {
+	blocked := make(map[int]bool)
+	if len(ids) == 0 {
+		return blocked, nil
+	}
+
+	err := blockUnsettled(ids, blocked)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []int
+	err = db.GetGormDb().
+		Table(model.TableSyncEventLog+" as s").
+		Select("s.id").
+		Where("s.id in ?", ids).
+		Where("exists (?)", db.GetGormDb().
+			Table(model.TableSyncEventLog+" as e").
+			Select("1").
+			Where("e.user_id = s.user_id and e.chain_id = s.chain_id").
+			Where("e."+model.SyncStatusCol+" not in ?", []SyncStatus{StatusSuccess, StatusFailed, StatusIgnored}).
+			Where("(e.block_height, e.event_index, e.sub_index, e.id) < (s.block_height, s.event_index, s.sub_index, s.id)")).
+		Pluck("s.id", &rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range rows {
+		blocked[id] = true
+	}
+
+	return blocked, nil
+}
+
+// blockUnsettled 标记所在高度超过应用链最低监听进度的记录，未配置的应用链不做限制
+func blockUnsettled(ids []int, blocked map[int]bool) error {
+	var srs []*model.SyncEventLog
+	err := db.GetGormDb().
+		Table(model.TableSyncEventLog).
+		Select("id, chain_id, block_height").
+		Where("id in ?", ids).
+		Find(&srs).Error
+	if err != nil {
+		return err
+	}
+
+	settled := make(map[string]int64)
+	for _, sr := range srs {
+		if sr.ChainId == "" {
+			continue
+		}
+
+		h, ok := settled[sr.ChainId]
+		if !ok {
+			cc, err := findChain(sr.ChainId)
+			if err != nil {
+				continue
+			}
+			h, err = settledHeight(cc)
+			if err != nil {
+				return err
+			}
+			settled[sr.ChainId] = h
+		}
+
+		if sr.BlockHeight > h {
+			blocked[sr.ID] = true
+		}
+	}
+
+	return nil
+}
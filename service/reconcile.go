@@ -0,0 +1,173 @@
+package service
+
+// 定时对账：比较 gateway 中已授权用户的链上最新余额与最近一次同步的余额，不一致时写入对账报告
+import (
+	"chain-proxy/config"
+	"chain-proxy/db"
+	"chain-proxy/db/model"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	ReconcileMismatch = "mismatch" // 同一高度下余额不一致
+	ReconcileLagging  = "lagging"  // 链上状态晚于已同步高度，同步尚未追上
+)
+
+// Reconcile 定时对账任务
+func Reconcile(ctx context.Context) error {
+	rc := config.GetConfigInstance().Reconcile
+	if rc == nil || rc.Interval <= 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(time.Duration(rc.Interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			runId, n, err := reconcileOnce(ctx)
+			if err != nil {
+				fmt.Printf("reconcile %s failed: %v\n", runId, err)
+				continue
+			}
+			fmt.Printf("reconcile %s done, %d discrepancies\n", runId, n)
+
+		case <-ctx.Done():
+			fmt.Printf("reconcile task recv ctx cancel signal, will close\n")
+			return ctx.Err()
+		}
+	}
+}
+
+// reconcileOnce 对所有已授权用户执行一次对账，返回对账批次与不一致的数量
+func reconcileOnce(ctx context.Context) (string, int, error) {
+	runId := uuid.New().String()
+
+	var users []*model.UserAuth
+	err := db.GetGormDb().
+		Table(model.TableUserAuth).
+		Find(&users).Error
+	if err != nil {
+		return runId, 0, err
+	}
+
+	var n int
+	for _, uar := range users {
+		if ctx.Err() != nil {
+			return runId, n, ctx.Err()
+		}
+
+		report, err := reconcileUser(uar)
+		if err != nil {
+			// 单个用户失败不影响其他用户对账
+			fmt.Printf("reconcile user %s failed: %v\n", uar.UserId, err)
+			continue
+		}
+		if report == nil {
+			continue
+		}
+
+		report.RunId = runId
+		err = db.GetGormDb().Table(model.TableReconciliationReport).Create(report).Error
+		if err != nil {
+			return runId, n, err
+		}
+		n++
+
+		if report.Reason == ReconcileMismatch {
+			raiseAlert("reconcile %s user %s balance mismatch at height %d: chain %d, synced %d",
+				runId, uar.UserId, report.ChainHeight, report.ChainBalance, report.SyncedBalance)
+		}
+	}
+
+	return runId, n, nil
+}
+
+// reconcileUser 比较单个用户的链上最新余额与最近一次同步的余额，一致时返回 nil
+func reconcileUser(uar *model.UserAuth) (*model.ReconciliationReport, error) {
+	wresp, err := getUserWalletInfo(uar.Addr)
+	if err != nil {
+		return nil, err
+	}
+	walletinfo, err := getLatestWalletInfo(wresp)
+	if err != nil {
+		return nil, err
+	}
+
+	// 最近一次同步的余额，没有同步记录时以授权时的余额为准
+	var last = new(model.SyncEventLog)
+	err = db.GetGormDb().
+		Table(model.TableSyncEventLog).
+		Where("user_id = ? and sync_status <> ?", uar.UserId, StatusIgnored).
+		Order("block_height desc, id desc").
+		Limit(1).
+		Find(last).Error
+	if err != nil {
+		return nil, err
+	}
+
+	syncedBalance, syncedHeight := uar.Balance, uar.BlockHeight
+	if last.ID != 0 {
+		syncedBalance, syncedHeight = last.BalanceAfter, last.BlockHeight
+	}
+
+	chainBalance, chainHeight := int64(walletinfo.Total), int64(walletinfo.BlockHeight)
+	if chainBalance == syncedBalance {
+		return nil, nil
+	}
+
+	reason := ReconcileMismatch
+	if chainHeight > syncedHeight {
+		reason = ReconcileLagging
+	}
+
+	return &model.ReconciliationReport{
+		UserId:        uar.UserId,
+		Addr:          uar.Addr,
+		ChainBalance:  chainBalance,
+		ChainHeight:   chainHeight,
+		SyncedBalance: syncedBalance,
+		SyncedHeight:  syncedHeight,
+		Diff:          chainBalance - syncedBalance,
+		Reason:        reason,
+	}, nil
+}
+
+// ListReconciliationReports 分页查询对账报告，runId、userId 为空时不过滤
+func ListReconciliationReports(runId, userId string, page, size int) ([]*model.ReconciliationReport, int64, error) {
+	if page <= 0 {
+		page = 1
+	}
+	if size <= 0 || size > 100 {
+		size = 20
+	}
+
+	tx := db.GetGormDb().Table(model.TableReconciliationReport)
+	if runId != "" {
+		tx = tx.Where("run_id = ?", runId)
+	}
+	if userId != "" {
+		tx = tx.Where("user_id = ?", userId)
+	}
+
+	var total int64
+	err := tx.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var reports []*model.ReconciliationReport
+	err = tx.Order("id desc").
+		Offset((page - 1) * size).
+		Limit(size).
+		Find(&reports).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return reports, total, nil
+}
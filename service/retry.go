@@ -0,0 +1,107 @@
+package service
+
+// 战略重试调度：定时认领到期的待发送记录，通过 sink 重新推送
+// 推送失败的记录按 retry_count 指数退避设置 next_retry_at，达到最大重试次数后标记为失败，不再调度
+import (
+	"chain-proxy/config"
+	"chain-proxy/db"
+	"chain-proxy/db/model"
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	defaultRetryInterval    = 30 * time.Second
+	defaultRetryBatchSize   = 100
+	defaultRetryBaseBackoff = 10 * time.Second
+	defaultRetryMaxBackoff  = time.Hour
+)
+
+// RetryPending 定时重试任务，始终运行：推送失败、租约回收、暂停释放与顺序等待的记录都依赖它重新推送
+func RetryPending(ctx context.Context) error {
+	interval := defaultRetryInterval
+	if rc := config.GetConfigInstance().Retry; rc != nil && rc.Interval > 0 {
+		interval = time.Duration(rc.Interval) * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			n, err := retryOnce(ctx, interval)
+			if err != nil {
+				fmt.Printf("retry pending events failed: %v\n", err)
+				continue
+			}
+			if n > 0 {
+				fmt.Printf("retry pending events done, %d dispatched\n", n)
+			}
+
+		case <-ctx.Done():
+			fmt.Printf("retry task recv ctx cancel signal, will close\n")
+			return ctx.Err()
+		}
+	}
+}
+
+// retryOnce 将一批到期的待发送记录分发重新推送，返回分发的数量
+// 从未推送失败过的待发送记录（如推送前进程退出）在写入 grace 时间后才会被调度，以免与监听任务争抢
+// 尚未校验余额连续性的记录由校验后分发，不在这里调度
+func retryOnce(ctx context.Context, grace time.Duration) (int, error) {
+	now := time.Now()
+
+	query := db.GetGormDb().
+		Table(model.TableSyncEventLog).
+		Where(model.SyncStatusCol+" = ? and "+model.CheckedCol+" = ?", StatusPending, true).
+		Where("("+model.NextRetryAtCol+" <= ? or ("+model.NextRetryAtCol+" is null and created_at <= ?))", now, now.Add(-grace)).
+		Where("user_id not in (?)", db.GetGormDb().Table(model.TableUserAuth).Select("user_id").Where("held = ?", true))
+
+	var srs []*model.SyncEventLog
+	err := query.
+		Order("block_height, event_index, sub_index").
+		Limit(retryBatchSize()).
+		Find(&srs).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	for _, sr := range srs {
+		if ctx.Err() != nil {
+			return n, ctx.Err()
+		}
+
+		// 已在通道中排队的记录不重复分发，已被其他任务认领的记录由 pushEvent 的条件更新跳过
+		if dispatchPush(sr, pushPolicyOf(sr.EventType)) {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// retryBackoff 计算第 retryCount 次战略失败后到下次重试的等待时间
+func retryBackoff(retryCount int) time.Duration {
+	base, max := defaultRetryBaseBackoff, defaultRetryMaxBackoff
+	if rc := config.GetConfigInstance().Retry; rc != nil {
+		if rc.BaseBackoff > 0 {
+			base = time.Duration(rc.BaseBackoff) * time.Second
+		}
+		if rc.MaxBackoff > 0 {
+			max = time.Duration(rc.MaxBackoff) * time.Second
+		}
+	}
+
+	return expBackoff(base, max, retryCount)
+}
+
+func retryBatchSize() int {
+	if rc := config.GetConfigInstance().Retry; rc != nil && rc.BatchSize > 0 {
+		return rc.BatchSize
+	}
+
+	return defaultRetryBatchSize
+}
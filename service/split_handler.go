@@ -0,0 +1,105 @@
+package service
+
+// 积分拆分事件：记录积分在未拆分与已拆分之间的移动，并向接收方推送拆分后的两类积分
+import (
+	"chain-proxy/chain"
+	"chain-proxy/db/model"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"sort"
+)
+
+const (
+	// HandlerSplit 积分拆分事件处理器
+	HandlerSplit = "split"
+)
+
+// splitHandler 积分拆分事件处理器
+var splitHandler = &EventHandler{
+	Name:    HandlerSplit,
+	Decode:  decodeSplitEvent,
+	Persist: persistSplitEvent,
+}
+
+// decodeSplitEvent 解析积分拆分事件，EventData 中的每一项对应一个地址的拆分
+func decodeSplitEvent(ev *chain.ContractEvent) ([]*EventEntry, error) {
+	if len(ev.EventData) == 0 {
+		return nil, errors.New("event data is empty")
+	}
+
+	entries := make([]*EventEntry, len(ev.EventData))
+	for i, data := range ev.EventData {
+		evData := new(SplitEventInfo)
+		err := json.Unmarshal([]byte(data), evData)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to unmarshal event data[%d] into SplitEventInfo struct", i)
+		}
+
+		if evData.Wallet == nil {
+			return nil, errors.Errorf("event data[%d] wallet is nil", i)
+		}
+
+		entries[i] = &EventEntry{
+			Address:     evData.Address,
+			Height:      evData.Height,
+			Balance:     evData.Balance,
+			ChangeValue: 0,
+			Payload: &SplitPayload{
+				Addr:             evData.Address,
+				Balance:          evData.Balance,
+				SplitMap:         evData.SplitMap,
+				IntegralMap:      evData.Wallet.IntegralMap,
+				SplitIntegralMap: evData.Wallet.SplitIntegralMap,
+			},
+		}
+	}
+
+	return entries, nil
+}
+
+// persistSplitEvent 写入同步记录，新写入时同一事务中按积分 hash 记录拆分明细
+func persistSplitEvent(tx *gorm.DB, sr *model.SyncEventLog) (bool, error) {
+	inserted, err := persistSyncEventLog(tx, sr)
+	if err != nil || !inserted {
+		return inserted, err
+	}
+
+	payload := new(SplitPayload)
+	err = json.Unmarshal([]byte(sr.EventPayload), payload)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to unmarshal split payload")
+	}
+
+	if len(payload.SplitMap) == 0 {
+		return true, nil
+	}
+
+	hashes := make([]string, 0, len(payload.SplitMap))
+	for hash := range payload.SplitMap {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+
+	logs := make([]*model.IntegralSplitLog, 0, len(hashes))
+	for _, hash := range hashes {
+		logs = append(logs, &model.IntegralSplitLog{
+			SyncEventId:  sr.ID,
+			IntegralHash: hash,
+			ChainId:      sr.ChainId,
+			UserId:       sr.UserId,
+			TxId:         sr.TxId,
+			BlockHeight:  sr.BlockHeight,
+			Amount:       int64(payload.SplitMap[hash]),
+			UnsplitAfter: int64(payload.IntegralMap[hash]),
+			SplitAfter:   int64(payload.SplitIntegralMap[hash]),
+		})
+	}
+
+	err = tx.Table(model.TableIntegralSplitLog).Create(&logs).Error
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"chain-proxy/chain"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeSplitEvent(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []string
+		want    []*EventEntry
+		wantErr bool
+	}{
+		{
+			name: "single address",
+			data: []string{`{"address":"a","height":12,"balance":30,"txId":"tx1","splitMap":{"h1":5},` +
+				`"wallet":{"integralMap":{"h1":10},"splitIntegralMap":{"h1":20}}}`},
+			want: []*EventEntry{
+				{
+					Address: "a",
+					Height:  12,
+					Balance: 30,
+					Payload: &SplitPayload{
+						Addr:             "a",
+						Balance:          30,
+						SplitMap:         map[string]int{"h1": 5},
+						IntegralMap:      map[string]int{"h1": 10},
+						SplitIntegralMap: map[string]int{"h1": 20},
+					},
+				},
+			},
+		},
+		{
+			name: "batch",
+			data: []string{
+				`{"address":"a","height":12,"balance":30,"wallet":{}}`,
+				`{"address":"b","height":12,"balance":40,"wallet":{}}`,
+			},
+			want: []*EventEntry{
+				{Address: "a", Height: 12, Balance: 30, Payload: &SplitPayload{Addr: "a", Balance: 30}},
+				{Address: "b", Height: 12, Balance: 40, Payload: &SplitPayload{Addr: "b", Balance: 40}},
+			},
+		},
+		{
+			name:    "empty event data",
+			wantErr: true,
+		},
+		{
+			name:    "invalid json",
+			data:    []string{`{"address":`},
+			wantErr: true,
+		},
+		{
+			name:    "missing wallet",
+			data:    []string{`{"address":"a","height":12,"balance":30}`},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeSplitEvent(&chain.ContractEvent{EventData: tt.data})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("err = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package service
+
+// 实时推送同步记录：新记录落库后通知订阅方，订阅方按 id 从 sync_event_log 中读取
+// 通知只是唤醒信号，不携带数据，断线重连后按 Last-Event-ID 从同步记录中重放
+import (
+	"chain-proxy/db"
+	"chain-proxy/db/model"
+	"sync"
+	"time"
+)
+
+const streamBatchSize = 100
+
+var (
+	streamMu sync.Mutex
+	// 订阅新记录通知的 channel，容量为 1，未及时消费的通知会合并
+	streamSubs = make(map[chan struct{}]struct{})
+)
+
+// SubscribeInserts 订阅新记录落库的通知，返回的函数用于取消订阅
+func SubscribeInserts() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	streamMu.Lock()
+	streamSubs[ch] = struct{}{}
+	streamMu.Unlock()
+
+	return ch, func() {
+		streamMu.Lock()
+		delete(streamSubs, ch)
+		streamMu.Unlock()
+	}
+}
+
+// notifyInserted 通知所有订阅方有新记录落库，不阻塞监听任务
+func notifyInserted() {
+	streamMu.Lock()
+	defer streamMu.Unlock()
+
+	for ch := range streamSubs {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// StreamEventsAfter 按 id 升序读取 after 之后的一批同步记录，topic、userId 为空时不过滤
+// 与拉取接口一样只读取已过 EventSettleDelay 的记录，断线按 Last-Event-ID 重放时不会遗漏较晚提交的较小 id
+func StreamEventsAfter(after int, topic, userId string) ([]*model.SyncEventLog, error) {
+	var srs []*model.SyncEventLog
+	err := eventsAfter(after, topic, userId).
+		Order("id").
+		Limit(streamBatchSize).
+		Find(&srs).Error
+
+	return srs, err
+}
+
+// LatestEventId 返回已过 EventSettleDelay 的最大同步记录 id，新建立且没有 Last-Event-ID 的连接从这里开始
+func LatestEventId() (int, error) {
+	var id int
+	err := db.GetGormDb().
+		Table(model.TableSyncEventLog).
+		Where("created_at <= ?", time.Now().Add(-EventSettleDelay)).
+		Select("coalesce(max(id), 0)").
+		Scan(&id).Error
+
+	return id, err
+}
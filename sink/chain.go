@@ -0,0 +1,80 @@
+package sink
+
+import (
+	"chain-proxy/chain"
+	"chainmaker.org/chainmaker/pb-go/v2/common"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/pkg/errors"
+	"strconv"
+)
+
+// ChainSink 以同步等待结果的方式调用数币链上配置的合约方法
+// 只有交易执行成功才视为送达，数币链上的交易 id 回填到 Event.TargetTxId
+type ChainSink struct {
+	client *chain.TargetClient
+}
+
+func NewChainSink() (*ChainSink, error) {
+	tc, err := chain.GetTargetClient()
+	if err != nil {
+		return nil, err
+	}
+
+	if tc == nil {
+		return nil, errors.New("target chain not configured")
+	}
+
+	return &ChainSink{
+		client: tc,
+	}, nil
+}
+
+// Deliver 只有交易执行成功才返回 nil，不会在没有交易回执的情况下被标记为成功
+// 交易 id 由同步记录确定性地生成，重试时不会在数币链上重复执行
+func (s *ChainSink) Deliver(ctx context.Context, ev *Event) error {
+	txId, err := s.client.Invoke(targetTxId(ev), buildTargetKvs(ev))
+	if err != nil {
+		return err
+	}
+
+	ev.TargetTxId = txId
+	return nil
+}
+
+// targetTxId 根据应用链与同步记录 id 生成数币链上的交易 id，格式与 sdk 生成的 64 位十六进制一致
+func targetTxId(ev *Event) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("chain-proxy:%s:%d", ev.ChainId, ev.Id)))
+	return hex.EncodeToString(sum[:])
+}
+
+// buildTargetKvs 构造调用数币链合约的参数，用户以数币 dcid 标识
+func buildTargetKvs(ev *Event) []*common.KeyValuePair {
+	params := []struct {
+		key   string
+		value string
+	}{
+		{"syncEventId", strconv.Itoa(ev.Id)},
+		{"dcid", ev.Dcid},
+		{"userId", ev.UserId},
+		{"chainId", ev.ChainId},
+		{"txId", ev.TxId},
+		{"blockHeight", strconv.FormatInt(ev.BlockHeight, 10)},
+		{"eventType", ev.EventType},
+		{"balance", strconv.FormatInt(ev.Balance, 10)},
+		{"changeValue", strconv.FormatInt(ev.ChangeValue, 10)},
+		{"payload", string(ev.Payload)},
+	}
+
+	kvs := make([]*common.KeyValuePair, 0, len(params))
+	for _, p := range params {
+		kvs = append(kvs, &common.KeyValuePair{
+			Key:   p.key,
+			Value: []byte(p.value),
+		})
+	}
+
+	return kvs
+}
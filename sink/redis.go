@@ -0,0 +1,93 @@
+package sink
+
+import (
+	"chain-proxy/config"
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisSink 将同步事件 XADD 到配置的 redis stream，消息字段中带上 sync_event_log 的 id
+type RedisSink struct {
+	client *redis.Client
+	stream string
+	maxLen int64
+}
+
+func NewRedisSink(conf *config.Redis) (*RedisSink, error) {
+	if conf.Addr == "" {
+		return nil, errors.New("redis addr is required")
+	}
+	if conf.Stream == "" {
+		return nil, errors.New("redis stream is required")
+	}
+
+	cli := redis.NewClient(&redis.Options{
+		Addr:     conf.Addr,
+		Password: conf.Password,
+		DB:       conf.DB,
+	})
+
+	err := cli.Ping(context.Background()).Err()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to connect redis %s", conf.Addr)
+	}
+
+	return &RedisSink{
+		client: cli,
+		stream: conf.Stream,
+		maxLen: conf.MaxLen,
+	}, nil
+}
+
+func (s *RedisSink) Deliver(ctx context.Context, ev *Event) error {
+	_, err := s.client.XAdd(ctx, s.xAddArgs(ev)).Result()
+	if err != nil {
+		return errors.Wrapf(err, "failed to xadd event %d to stream %s", ev.Id, s.stream)
+	}
+
+	return nil
+}
+
+// DeliverBatch 以 pipeline 批量 XADD，每条命令的结果对应一个事件
+func (s *RedisSink) DeliverBatch(ctx context.Context, evs []*Event) []error {
+	cmds := make([]*redis.StringCmd, len(evs))
+	// pipeline 执行失败时错误会写入每条命令，这里按命令逐个检查
+	_, _ = s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, ev := range evs {
+			cmds[i] = pipe.XAdd(ctx, s.xAddArgs(ev))
+		}
+		return nil
+	})
+
+	errs := make([]error, len(evs))
+	for i, cmd := range cmds {
+		if cmd.Err() != nil {
+			errs[i] = errors.Wrapf(cmd.Err(), "failed to xadd event %d to stream %s", evs[i].Id, s.stream)
+		}
+	}
+
+	return errs
+}
+
+// xAddArgs 构造 XADD 参数，消息 id 由 redis 生成，同步记录 id 放在 syncEventId 字段中供消费方去重
+func (s *RedisSink) xAddArgs(ev *Event) *redis.XAddArgs {
+	data, _ := json.Marshal(ev)
+
+	args := &redis.XAddArgs{
+		Stream: s.stream,
+		Values: map[string]interface{}{
+			"syncEventId": ev.Id,
+			"userId":      ev.UserId,
+			"eventType":   ev.EventType,
+			"data":        string(data),
+		},
+	}
+	if s.maxLen > 0 {
+		args.MaxLen = s.maxLen
+		args.Approx = true
+	}
+
+	return args
+}
@@ -0,0 +1,124 @@
+package sink
+
+import (
+	"chain-proxy/config"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/alicebob/miniredis/v2"
+	"strings"
+	"testing"
+)
+
+func newTestRedisSink(t *testing.T, maxLen int64) (*RedisSink, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	s, err := NewRedisSink(&config.Redis{
+		Addr:   mr.Addr(),
+		Stream: "sync_event",
+		MaxLen: maxLen,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s, mr
+}
+
+func TestRedisSinkDeliver(t *testing.T) {
+	s, mr := newTestRedisSink(t, 0)
+
+	err := s.Deliver(context.Background(), &Event{Id: 42, UserId: "u1", EventType: "collect", Balance: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := mr.Stream("sync_event")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+
+	fields := make(map[string]string)
+	values := entries[0].Values
+	for i := 0; i+1 < len(values); i += 2 {
+		fields[values[i]] = values[i+1]
+	}
+
+	if fields["syncEventId"] != "42" {
+		t.Fatalf("syncEventId = %q, want 42", fields["syncEventId"])
+	}
+	if fields["userId"] != "u1" || fields["eventType"] != "collect" {
+		t.Fatalf("unexpected fields %v", fields)
+	}
+
+	var ev Event
+	err = json.Unmarshal([]byte(fields["data"]), &ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.Id != 42 || ev.Balance != 100 {
+		t.Fatalf("data = %+v", ev)
+	}
+}
+
+func TestRedisSinkMaxLen(t *testing.T) {
+	s, mr := newTestRedisSink(t, 2)
+
+	for i := 1; i <= 5; i++ {
+		err := s.Deliver(context.Background(), &Event{Id: i})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := mr.Stream("sync_event")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want stream trimmed to 2", len(entries))
+	}
+}
+
+func TestRedisSinkDeliverBatch(t *testing.T) {
+	s, mr := newTestRedisSink(t, 0)
+
+	evs := []*Event{{Id: 1}, {Id: 2}, {Id: 3}}
+	for i, err := range s.DeliverBatch(context.Background(), evs) {
+		if err != nil {
+			t.Fatalf("event %d: %v", evs[i].Id, err)
+		}
+	}
+
+	entries, err := mr.Stream("sync_event")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+
+	// stream key 被占用为其他类型时，每条命令各自返回错误并对应到自己的事件
+	mr.Del("sync_event")
+	err = mr.Set("sync_event", "not a stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := s.DeliverBatch(context.Background(), evs)
+	if len(errs) != len(evs) {
+		t.Fatalf("got %d results, want %d", len(errs), len(evs))
+	}
+	for i, err := range errs {
+		if err == nil {
+			t.Fatalf("event %d: err = nil, want WRONGTYPE", evs[i].Id)
+		}
+		if !strings.Contains(err.Error(), fmt.Sprintf("event %d ", evs[i].Id)) {
+			t.Fatalf("event %d: error %q not mapped to its event", evs[i].Id, err)
+		}
+	}
+}
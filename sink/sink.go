@@ -0,0 +1,90 @@
+package sink
+
+import (
+	"chain-proxy/config"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	TypeChain   = "chain"   // 调用数币链合约
+	TypeWebhook = "webhook" // http 推送
+	TypeRedis   = "redis"   // 写入 redis stream
+	TypeLog     = "log"     // 仅打印，用于开发环境
+)
+
+// Event 推送给接收方的同步事件信封
+type Event struct {
+	Id           int             `json:"id"` // sync_event_log 的 id
+	ChainId      string          `json:"chainId"`
+	UserId       string          `json:"userId"`
+	Dcid         string          `json:"dcid"`
+	EventType    string          `json:"eventType"`
+	Topic        string          `json:"topic"`
+	ContractName string          `json:"contractName"`
+	TxId         string          `json:"txId"`
+	BlockHeight  int64           `json:"blockHeight"`
+	Balance      int64           `json:"balance"`
+	ChangeValue  int64           `json:"changeValue"`
+	Payload      json.RawMessage `json:"payload,omitempty"` // 各事件类型的附加数据
+
+	// 送达数币链时由 sink 回填的交易 id
+	TargetTxId string `json:"-"`
+}
+
+// Sink 同步事件的送达方式，Deliver 返回 nil 表示接收方已确认收到
+type Sink interface {
+	Deliver(ctx context.Context, ev *Event) error
+}
+
+// BatchSink 支持批量送达的 sink，返回与 evs 一一对应的送达结果，nil 表示该事件已确认收到
+type BatchSink interface {
+	Sink
+	DeliverBatch(ctx context.Context, evs []*Event) []error
+}
+
+// batchErrors 返回所有事件都以 err 失败的送达结果
+func batchErrors(n int, err error) []error {
+	errs := make([]error, n)
+	for i := range errs {
+		errs[i] = err
+	}
+
+	return errs
+}
+
+// New 根据配置创建 sink，未配置送达方式时返回错误，避免记录在没有送达的情况下被标记为成功
+func New(conf *config.Sink) (Sink, error) {
+	if conf == nil || conf.Type == "" {
+		return nil, fmt.Errorf("sink type is required")
+	}
+
+	typ := conf.Type
+	switch typ {
+	case TypeChain:
+		return NewChainSink()
+	case TypeWebhook:
+		if conf.Webhook == nil {
+			return nil, fmt.Errorf("webhook sink config is required")
+		}
+		return NewWebhookSink(conf.Webhook)
+	case TypeRedis:
+		if conf.Redis == nil {
+			return nil, fmt.Errorf("redis sink config is required")
+		}
+		return NewRedisSink(conf.Redis)
+	case TypeLog:
+		return new(LogSink), nil
+	default:
+		return nil, fmt.Errorf("unknown sink type %s", typ)
+	}
+}
+
+// LogSink 仅打印同步事件，不做实际送达
+type LogSink struct{}
+
+func (s *LogSink) Deliver(ctx context.Context, ev *Event) error {
+	fmt.Printf("[LogSink] deliver event id %d user %s balance %d change %d\n", ev.Id, ev.UserId, ev.Balance, ev.ChangeValue)
+	return nil
+}
@@ -0,0 +1,130 @@
+package sink
+
+import (
+	"bytes"
+	"chain-proxy/config"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	// 默认请求超时，单位：秒
+	defaultWebhookTimeout = 10
+	// 读取的响应体上限
+	maxWebhookRespSize = 1 << 20
+)
+
+// WebhookSink 将同步事件以 json 信封 POST 到配置的地址，只有 2xx 视为送达
+type WebhookSink struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func NewWebhookSink(conf *config.Webhook) (*WebhookSink, error) {
+	if conf.Url == "" {
+		return nil, errors.New("webhook url is required")
+	}
+
+	timeout := conf.Timeout
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+
+	return &WebhookSink{
+		url:     conf.Url,
+		headers: conf.Headers,
+		client: &http.Client{
+			Timeout: time.Duration(timeout) * time.Second,
+		},
+	}, nil
+}
+
+// batchResponse 批量推送的响应，接收方在 failed 中列出未能接收的事件，响应体不是该格式时视为全部接收
+type batchResponse struct {
+	Failed []struct {
+		Id    int    `json:"id"`
+		Error string `json:"error"`
+	} `json:"failed"`
+}
+
+func (s *WebhookSink) Deliver(ctx context.Context, ev *Event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.post(ctx, body)
+	return err
+}
+
+// DeliverBatch 将一批事件以 json 数组 POST 到配置的地址，非 2xx 视为整批失败，2xx 时按响应中的 failed 逐条判断
+func (s *WebhookSink) DeliverBatch(ctx context.Context, evs []*Event) []error {
+	body, err := json.Marshal(evs)
+	if err != nil {
+		return batchErrors(len(evs), err)
+	}
+
+	resp, err := s.post(ctx, body)
+	if err != nil {
+		return batchErrors(len(evs), err)
+	}
+
+	// 2xx 响应中没有可解析的逐条结果（空响应体、纯文本等）时视为全部接收
+	errs := make([]error, len(evs))
+	br := new(batchResponse)
+	if json.Unmarshal(resp, br) != nil {
+		return errs
+	}
+
+	failed := make(map[int]string, len(br.Failed))
+	for _, f := range br.Failed {
+		failed[f.Id] = f.Error
+	}
+	for i, ev := range evs {
+		if msg, ok := failed[ev.Id]; ok {
+			errs[i] = fmt.Errorf("webhook rejected event %d: %s", ev.Id, msg)
+		}
+	}
+
+	return errs
+}
+
+// post 发送请求并返回响应体
+func (s *WebhookSink) post(ctx context.Context, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("webhook http status code %d, resp: %s", resp.StatusCode, msg)
+	}
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxWebhookRespSize))
+	if err != nil {
+		return nil, err
+	}
+
+	// 读完响应体以便复用连接
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	return respBody, nil
+}
@@ -0,0 +1,148 @@
+package sink
+
+import (
+	"chain-proxy/config"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhookSinkDeliver(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{name: "ok", status: http.StatusOK},
+		{name: "no content", status: http.StatusNoContent},
+		{name: "bad request", status: http.StatusBadRequest, wantErr: true},
+		{name: "server error", status: http.StatusInternalServerError, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Event
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_ = json.NewDecoder(r.Body).Decode(&got)
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			s, err := NewWebhookSink(&config.Webhook{Url: srv.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = s.Deliver(context.Background(), &Event{Id: 7, UserId: "u1"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.Id != 7 || got.UserId != "u1" {
+				t.Fatalf("receiver got %+v", got)
+			}
+		})
+	}
+}
+
+func TestWebhookSinkHeaders(t *testing.T) {
+	var auth, contentType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		contentType = r.Header.Get("Content-Type")
+	}))
+	defer srv.Close()
+
+	s, err := NewWebhookSink(&config.Webhook{
+		Url:     srv.URL,
+		Headers: map[string]string{"Authorization": "Bearer token"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Deliver(context.Background(), &Event{Id: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if auth != "Bearer token" {
+		t.Fatalf("Authorization = %q", auth)
+	}
+	if contentType != "application/json" {
+		t.Fatalf("Content-Type = %q", contentType)
+	}
+}
+
+func TestWebhookSinkTimeout(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer srv.Close()
+	defer close(done)
+
+	s, err := NewWebhookSink(&config.Webhook{Url: srv.URL, Timeout: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	err = s.Deliver(context.Background(), &Event{Id: 1})
+	if err == nil {
+		t.Fatal("err = nil, want timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("deliver took %v, want client timeout", elapsed)
+	}
+}
+
+func TestWebhookSinkDeliverBatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		resp    string
+		wantErr []bool
+	}{
+		{name: "empty body", status: http.StatusOK, wantErr: []bool{false, false, false}},
+		{name: "all delivered", status: http.StatusOK, resp: `{"failed":[]}`, wantErr: []bool{false, false, false}},
+		{name: "partial failure", status: http.StatusOK, resp: `{"failed":[{"id":2,"error":"unknown user"}]}`, wantErr: []bool{false, true, false}},
+		{name: "plain text", status: http.StatusOK, resp: "ok", wantErr: []bool{false, false, false}},
+		{name: "server error", status: http.StatusInternalServerError, resp: "boom", wantErr: []bool{true, true, true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []*Event
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_ = json.NewDecoder(r.Body).Decode(&got)
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.resp))
+			}))
+			defer srv.Close()
+
+			s, err := NewWebhookSink(&config.Webhook{Url: srv.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			evs := []*Event{{Id: 1}, {Id: 2}, {Id: 3}}
+			errs := s.DeliverBatch(context.Background(), evs)
+			if len(errs) != len(evs) {
+				t.Fatalf("len(errs) = %d, want %d", len(errs), len(evs))
+			}
+			for i, err := range errs {
+				if (err != nil) != tt.wantErr[i] {
+					t.Fatalf("errs[%d] = %v, wantErr %v", i, err, tt.wantErr[i])
+				}
+			}
+			if len(got) != len(evs) {
+				t.Fatalf("receiver got %d events, want %d", len(got), len(evs))
+			}
+		})
+	}
+}